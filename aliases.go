@@ -0,0 +1,137 @@
+package esu
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+	logger "github.com/sirupsen/logrus"
+	context "golang.org/x/net/context"
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+// RebuildFlags are flags you can pass to Rebuild.
+type RebuildFlags struct {
+	// DeleteOld deletes the indexes the alias pointed to once it is swapped
+	DeleteOld bool
+
+	// Permanent are the flags the new index is made permanent with
+	Permanent PermanentFlags
+}
+
+func (mgr *indexManager) GetAliases(ctx context.Context, indexName string) (map[string][]string, error) {
+	svc := mgr.client.Aliases()
+	if indexName != "" {
+		svc = svc.Index(indexName)
+	}
+	resp, err := svc.Do(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not get aliases")
+	}
+
+	aliases := make(map[string][]string, len(resp.Indices))
+	for index, result := range resp.Indices {
+		names := []string{}
+		for _, alias := range result.Aliases {
+			names = append(names, alias.AliasName)
+		}
+		sort.Strings(names)
+		aliases[index] = names
+	}
+	return aliases, nil
+}
+
+func (mgr *indexManager) AddAlias(ctx context.Context, indexName, alias string) error {
+	logger.Infof("Adding alias %q to index %q", alias, indexName)
+	return mgr.updateAliases(ctx, mgr.client.Alias().Add(indexName, alias),
+		"Unable to add alias %q to index %q", alias, indexName)
+}
+
+func (mgr *indexManager) RemoveAlias(ctx context.Context, indexName, alias string) error {
+	logger.Infof("Removing alias %q from index %q", alias, indexName)
+	return mgr.updateAliases(ctx, mgr.client.Alias().Remove(indexName, alias),
+		"Unable to remove alias %q from index %q", alias, indexName)
+}
+
+func (mgr *indexManager) SwapAlias(ctx context.Context, alias, indexName string) ([]string, error) {
+	resp, err := mgr.client.Aliases().Do(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not get aliases")
+	}
+
+	svc := mgr.client.Alias()
+	var previous []string
+	for _, index := range resp.IndicesByAlias(alias) {
+		if index == indexName {
+			continue
+		}
+		svc = svc.Remove(index, alias)
+		previous = append(previous, index)
+	}
+	sort.Strings(previous)
+	svc = svc.Add(indexName, alias)
+
+	logger.Infof("Pointing alias %q at index %q instead of %q", alias, indexName, previous)
+	if err := mgr.updateAliases(ctx, svc, "Unable to point alias %q at index %q", alias, indexName); err != nil {
+		return nil, err
+	}
+	return previous, nil
+}
+
+// updateAliases runs the alias actions of svc in one request
+func (mgr *indexManager) updateAliases(ctx context.Context, svc *elastic.AliasService, format string, args ...interface{}) error {
+	resp, err := svc.Do(ctx)
+	if err != nil {
+		return errors.Wrapf(err, format, args...)
+	}
+	if !resp.Acknowledged {
+		return errors.Wrapf(ErrNotAcknowledged, format, args...)
+	}
+	return nil
+}
+
+// Rebuild replaces the index behind alias without downtime. It creates
+// indexName as a temporary index, lets fill load the documents, makes the
+// index permanent and atomically points the alias at it. If any step up to
+// the alias swap fails the new index is deleted and the alias is left alone.
+func Rebuild(
+	ctx context.Context,
+	mgr IndexManager,
+	alias, indexName string,
+	mappings interface{},
+	fill func(indexName string) error,
+	flags RebuildFlags) error {
+	if err := mgr.Create(ctx, indexName, CreateFlags{Temporary: true}, mappings); err != nil {
+		return err
+	}
+
+	// discard deletes the incomplete index before returning err, even if
+	// ctx is done
+	discard := func(err error, format string) error {
+		if delErr := mgr.Delete(context.Background(), indexName); delErr != nil {
+			logger.Warningf("Unable to delete incomplete index %q, ignoring: %s", indexName, delErr)
+		}
+		return errors.Wrapf(err, format, indexName)
+	}
+
+	if err := fill(indexName); err != nil {
+		return discard(err, "Unable to fill index %q")
+	}
+
+	if err := mgr.MakePermanent(ctx, indexName, flags.Permanent); err != nil {
+		return discard(err, "Unable to make index %q permanent")
+	}
+
+	previous, err := mgr.SwapAlias(ctx, alias, indexName)
+	if err != nil {
+		return discard(err, "Unable to point alias at index %q")
+	}
+
+	if flags.DeleteOld {
+		for _, index := range previous {
+			if err := mgr.Delete(ctx, index); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package esu
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+// newFakeClusterInfo serves canned cluster health, stats and nodes responses
+func newFakeClusterInfo(t *testing.T) *EsConnection {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/_cluster/health":
+			w.Write([]byte(`{"cluster_name":"test","status":"green","number_of_nodes":3,"number_of_data_nodes":2,"active_shards":10,"active_shards_percent_as_number":100}`))
+		case r.URL.Path == "/_cluster/stats":
+			w.Write([]byte(`{"cluster_name":"test","status":"green","indices":{"count":4,"shards":{"total":10,"primaries":5,"replication":1},"docs":{"count":100,"deleted":2}}}`))
+		case strings.HasPrefix(r.URL.Path, "/_nodes"):
+			w.Write([]byte(`{"cluster_name":"test","nodes":{"n1":{"name":"node-1","version":"5.6.0","http_address":"127.0.0.1:9200","transport_address":"127.0.0.1:9300","process":{"id":42}}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := elastic.NewClient(elastic.SetURL(srv.URL), elastic.SetSniff(false), elastic.SetHealthcheck(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &EsConnection{Client: client}
+}
+
+// row returns the cells of the table row whose first column is name
+func row(t *Table, name string) []string {
+	for _, r := range t.rows {
+		if len(r) > 0 && r[0] == name {
+			return r
+		}
+	}
+	return nil
+}
+
+func TestCluster_Health(t *testing.T) {
+	cn := newFakeClusterInfo(t)
+
+	res, err := cn.ClusterHealth(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ClusterName != "test" || res.Status != "green" {
+		t.Fatalf("unexpected health %+v", res)
+	}
+
+	table := ClusterHealthTable(res)
+	if r := row(table, "Total Nodes"); r == nil || r[1] != "3" {
+		t.Errorf("expected 3 total nodes, got %v", r)
+	}
+	if r := row(table, "Data Nodes"); r == nil || r[1] != "2" {
+		t.Errorf("expected 2 data nodes, got %v", r)
+	}
+}
+
+func TestCluster_Stats(t *testing.T) {
+	cn := newFakeClusterInfo(t)
+
+	res, err := cn.ClusterStats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tables := ClusterStatsTables(res)
+	if len(tables) < 2 || tables[0].header[0] != "Indices" || tables[1].header[0] != "Storage" {
+		t.Fatalf("expected indices and storage tables, got %d tables", len(tables))
+	}
+	if r := row(tables[0], "Shards"); r == nil || r[1] != "10 (5 Primaries)" {
+		t.Errorf("unexpected shards row %v", r)
+	}
+	if r := row(tables[1], "Total Documents"); r == nil || r[1] != "100 (2 Deleted)" {
+		t.Errorf("unexpected documents row %v", r)
+	}
+}
+
+func TestCluster_Nodes(t *testing.T) {
+	cn := newFakeClusterInfo(t)
+
+	res, err := cn.ClusterNodes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	table := ClusterNodesTable(res)
+	r := row(table, "n1")
+	if r == nil {
+		t.Fatal("expected a row for node n1")
+	}
+	if r[1] != "42" || r[2] != "node-1" || r[3] != "5.6.0" {
+		t.Errorf("unexpected node row %v", r)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/leffen/esu"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	register("alias list", command{usage: "[index]", help: "List the aliases of all or some indices", run: aliasList})
+	register("alias add", command{usage: "<index> <alias>", help: "Add an alias to an index", run: aliasAdd})
+	register("alias remove", command{usage: "<index> <alias>", help: "Remove an alias from an index", run: aliasRemove})
+	register("alias swap", command{usage: "[flags] <alias> <index>", help: "Atomically point an alias at another index", run: aliasSwap})
+}
+
+func aliasList(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	if len(args) > 1 {
+		return errors.New("Expected at most one index")
+	}
+	var index string
+	if len(args) == 1 {
+		index = args[0]
+	}
+
+	mgr, err := indexManager(cn, "")
+	if err != nil {
+		return err
+	}
+	aliases, err := mgr.GetAliases(ctx, index)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(aliases))
+	for name := range aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	t := esu.NewTable("Index", "Aliases")
+	for _, name := range names {
+		t.Add(name, strings.Join(aliases[name], ", "))
+	}
+	t.Print()
+	return nil
+}
+
+func aliasAdd(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	if len(args) != 2 {
+		return errors.New("Expected an index and an alias")
+	}
+
+	mgr, err := indexManager(cn, "")
+	if err != nil {
+		return err
+	}
+	return mgr.AddAlias(ctx, args[0], args[1])
+}
+
+func aliasRemove(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	if len(args) != 2 {
+		return errors.New("Expected an index and an alias")
+	}
+
+	mgr, err := indexManager(cn, "")
+	if err != nil {
+		return err
+	}
+	return mgr.RemoveAlias(ctx, args[0], args[1])
+}
+
+func aliasSwap(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	flags := commandFlags("alias swap")
+	deleteOld := flags.Bool("delete-old", false, "delete the indices the alias pointed to")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return errors.New("Expected an alias and an index")
+	}
+
+	mgr, err := indexManager(cn, "")
+	if err != nil {
+		return err
+	}
+	previous, err := mgr.SwapAlias(ctx, flags.Arg(0), flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Alias %s now points at %s instead of %s\n", flags.Arg(0), flags.Arg(1), strings.Join(previous, ", "))
+
+	if *deleteOld {
+		for _, index := range previous {
+			if err := mgr.Delete(ctx, index); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
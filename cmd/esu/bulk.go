@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/leffen/esu"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	register("bulk import", command{usage: "[flags] [file|-]", help: "Index newline delimited JSON documents, optionally gzipped, from a file or stdin", run: bulkImport})
+	register("bulk import-csv", command{usage: "[flags] [file|-]", help: "Index the rows of a CSV or TSV file, optionally gzipped, from a file or stdin", run: bulkImportCSV})
+}
+
+func bulkImport(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	flags := commandFlags("bulk import")
+	index := flags.String("index", "", "target index")
+	indexType := flags.String("type", "doc", "document type")
+	idField := flags.String("id-field", "", "dotted path of the field holding the document id")
+	hits := flags.Bool("hits", false, "read documents with metadata as written by bulk export")
+	actions := flags.Int("actions", 1000, "documents per bulk request")
+	size := flags.Int("size", 5<<20, "bytes per bulk request")
+	workers := flags.Int("workers", 2, "concurrent bulk requests")
+	deadLetters := flags.String("dead-letters", "", "file to append rejected documents to")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *index == "" {
+		flags.Usage()
+		return errors.New("Expected an index")
+	}
+
+	r, err := esu.OpenInput(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	reader, err := esu.NewNDJSONReader(r)
+	if err != nil {
+		return err
+	}
+	reader.IDField = *idField
+	reader.Hits = *hits
+
+	pump := esu.NewDatapump(cn, *index, *indexType, *actions, *size, *workers)
+	if *deadLetters != "" {
+		dl, err := esu.NewDeadLetterFile(*deadLetters)
+		if err != nil {
+			return err
+		}
+		defer dl.Close()
+		pump.Failures = dl
+	}
+
+	return pumpRecords(ctx, pump, reader.Pump, func() {
+		if reader.Malformed > 0 {
+			fmt.Printf("\nSkipped %d malformed of %d lines\n", reader.Malformed, reader.Lines)
+		}
+	})
+}
+
+func bulkImportCSV(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	flags := commandFlags("bulk import-csv")
+	index := flags.String("index", "", "target index")
+	indexType := flags.String("type", "doc", "document type")
+	idColumn := flags.String("id-column", "", "column holding the document id")
+	tsv := flags.Bool("tsv", false, "read tab separated values")
+	columns := flags.String("columns", "", "column types as name:type,... with types integer, float, boolean, date or string")
+	inferRows := flags.Int("infer-rows", esu.DefaultInferRows, "rows sampled to infer column types")
+	createIndex := flags.Bool("create", false, "create the index with mappings for the column types")
+	actions := flags.Int("actions", 1000, "documents per bulk request")
+	size := flags.Int("size", 5<<20, "bytes per bulk request")
+	workers := flags.Int("workers", 2, "concurrent bulk requests")
+	deadLetters := flags.String("dead-letters", "", "file to append rejected documents to")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *index == "" {
+		flags.Usage()
+		return errors.New("Expected an index")
+	}
+
+	types, err := parseColumnTypes(*columns)
+	if err != nil {
+		return err
+	}
+
+	r, err := esu.OpenInput(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	newReader := esu.NewCSVReader
+	if *tsv {
+		newReader = esu.NewTSVReader
+	}
+	reader, err := newReader(r)
+	if err != nil {
+		return err
+	}
+	reader.IDColumn = *idColumn
+	reader.Types = types
+	reader.InferRows = *inferRows
+
+	if *createIndex {
+		mappings, err := reader.Mapping(*indexType)
+		if err != nil {
+			return err
+		}
+		mgr, err := esu.NewIndexManager(cn.Client, nil)
+		if err != nil {
+			return err
+		}
+		if err := mgr.Create(ctx, *index, esu.CreateFlags{}, mappings); err != nil {
+			return err
+		}
+	}
+
+	pump := esu.NewDatapump(cn, *index, *indexType, *actions, *size, *workers)
+	if *deadLetters != "" {
+		dl, err := esu.NewDeadLetterFile(*deadLetters)
+		if err != nil {
+			return err
+		}
+		defer dl.Close()
+		pump.Failures = dl
+	}
+
+	return pumpRecords(ctx, pump, reader.Pump, func() {
+		if reader.Malformed > 0 {
+			fmt.Printf("\nSkipped %d malformed of %d rows\n", reader.Malformed, reader.Rows)
+		}
+	})
+}
+
+// parseColumnTypes parses column types given as name:type,...
+func parseColumnTypes(s string) (map[string]esu.ColumnType, error) {
+	types := map[string]esu.ColumnType{}
+	if s == "" {
+		return types, nil
+	}
+
+	for _, column := range strings.Split(s, ",") {
+		parts := strings.SplitN(column, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.Errorf("Invalid column type %q, expected name:type", column)
+		}
+
+		typ := esu.ColumnType(parts[1])
+		switch typ {
+		case esu.ColumnInteger, esu.ColumnFloat, esu.ColumnBoolean, esu.ColumnDate, esu.ColumnString:
+		default:
+			return nil, errors.Errorf("Unknown type %q of column %q", parts[1], parts[0])
+		}
+		types[parts[0]] = typ
+	}
+	return types, nil
+}
+
+// pumpRecords runs pump with the records read by read, and prints the stats
+// of the import once done
+func pumpRecords(ctx context.Context, pump *esu.Datapump, read func(context.Context, chan<- esu.PumpData) error, summary func()) error {
+	// The reader is stopped if the pump gives up early
+	readCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lc := make(chan esu.PumpData)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(lc)
+		readErr <- read(readCtx, lc)
+	}()
+
+	runErr := pump.Run(ctx, lc)
+	cancel()
+	// The reader has to be done before summary reads its counters
+	readerErr := <-readErr
+
+	printImportStats(pump.Stats())
+	if summary != nil {
+		summary()
+	}
+
+	if readerErr != nil && readerErr != context.Canceled {
+		return readerErr
+	}
+	return runErr
+}
+
+func printImportStats(stats esu.PumpStats) {
+	t := esu.NewTable("Import", "")
+	t.Add("Documents", stats.Rows)
+	t.Add("Succeeded", stats.Succeeded)
+	t.Add("Failed", stats.Failed)
+	t.Add("Elapsed", stats.Elapsed)
+	t.Add("Docs/sec", fmt.Sprintf("%.0f", stats.DocsPerSec))
+	t.Print()
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/leffen/esu"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	register("ping", command{help: "Check that the cluster is reachable", run: ping})
+	register("health", command{help: "Show cluster health", run: health})
+	register("stats", command{help: "Show cluster statistics", run: stats})
+	register("nodes", command{help: "List the nodes of the cluster", run: nodes})
+	register("settings put", command{usage: "[file|-]", help: "Update cluster settings from a JSON file or stdin", run: putSettings})
+}
+
+func ping(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	res, err := cn.Ping(ctx)
+	if err != nil {
+		return err
+	}
+	esu.PingTable(cn.URL.String(), res).Print()
+	return nil
+}
+
+func health(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	res, err := cn.ClusterHealth(ctx)
+	if err != nil {
+		return err
+	}
+	esu.ClusterHealthTable(res).Print()
+	return nil
+}
+
+func stats(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	res, err := cn.ClusterStats(ctx)
+	if err != nil {
+		return err
+	}
+	for _, t := range esu.ClusterStatsTables(res) {
+		t.Print()
+	}
+	return nil
+}
+
+func nodes(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	res, err := cn.ClusterNodes(ctx)
+	if err != nil {
+		return err
+	}
+	esu.ClusterNodesTable(res).Print()
+	return nil
+}
+
+func putSettings(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	var path string
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	body, err := readInput(path)
+	if err != nil {
+		return err
+	}
+	if !json.Valid(body) {
+		return errors.New("Settings are not valid JSON")
+	}
+
+	if err := cn.PutClusterSettings(ctx, string(body)); err != nil {
+		return err
+	}
+	fmt.Println("\nSettings updated succesfully.")
+	return nil
+}
+
+// readInput reads all of the file at path, or stdin if path is empty or "-"
+func readInput(path string) ([]byte, error) {
+	r, err := esu.OpenInput(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
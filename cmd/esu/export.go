@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/leffen/esu"
+	"github.com/pkg/errors"
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+func init() {
+	register("bulk export", command{usage: "[flags]", help: "Write the documents of an index as newline delimited JSON, readable by bulk import -hits", run: bulkExport})
+}
+
+func bulkExport(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	flags := commandFlags("bulk export")
+	index := flags.String("index", "", "index to export")
+	query := flags.String("query", "", "JSON query selecting the documents to export")
+	fields := flags.String("fields", "", "comma separated source fields to export, all if empty")
+	slices := flags.Int("slices", 1, "scrolls run in parallel")
+	size := flags.Int("size", esu.DefaultExportPageSize, "documents fetched per request")
+	gz := flags.Bool("gzip", false, "gzip the output")
+	output := flags.String("o", "-", "output file, stdout if -")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *index == "" {
+		flags.Usage()
+		return errors.New("Expected an index")
+	}
+
+	ex := esu.NewExporter(cn, *index)
+	ex.Slices = *slices
+	ex.PageSize = *size
+	ex.Gzip = *gz
+	if *fields != "" {
+		ex.Fields = strings.Split(*fields, ",")
+	}
+	if *query != "" {
+		if !json.Valid([]byte(*query)) {
+			return errors.New("Query is not valid JSON")
+		}
+		ex.Query = elastic.NewRawStringQuery(*query)
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return errors.Wrapf(err, "Unable to create %q", *output)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := ex.Export(ctx, w); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d documents from %s\n", ex.Exported(), *index)
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/leffen/esu"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	register("index create", command{usage: "[flags] <index>", help: "Create an index", run: indexCreate})
+	register("index delete", command{usage: "<index>", help: "Delete an index", run: indexDelete})
+	register("index list", command{help: "List all indices", run: indexList})
+	register("index make-permanent", command{usage: "[flags] <index>", help: "Restore durable settings of a temporary index", run: indexMakePermanent})
+}
+
+// indexManager creates an index manager with the index settings in the JSON file at path, if given
+func indexManager(cn *esu.EsConnection, path string) (esu.IndexManager, error) {
+	if path == "" {
+		return esu.NewIndexManager(cn.Client, nil)
+	}
+
+	body, err := readInput(path)
+	if err != nil {
+		return nil, err
+	}
+	settings := json.RawMessage(body)
+	return esu.NewIndexManager(cn.Client, &settings)
+}
+
+func indexCreate(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	flags := commandFlags("index create")
+	temporary := flags.Bool("temporary", false, "create with settings optimized for bulk loading")
+	settingsFile := flags.String("settings", "", "JSON file with index settings")
+	mappingsFile := flags.String("mappings", "", "JSON file with index mappings")
+	shards := flags.Int("shards", 0, "number of primary shards, by default from the settings or templates")
+	replicas := flags.Int("replicas", -1, "number of replicas, by default from the settings or templates")
+	aliases := flags.String("aliases", "", "comma separated aliases to add to the index")
+	activeShards := flags.String("wait-for-active-shards", "", "shard copies that must be active before returning, a number or all")
+	waitFor := flags.String("wait-for", "yellow", "index health to wait for, green, yellow or none")
+	timeout := flags.String("timeout", "30s", "how long to wait for the index")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("Expected an index name")
+	}
+
+	create := esu.CreateFlags{
+		Temporary:           *temporary,
+		WaitForActiveShards: *activeShards,
+		WaitForStatus:       *waitFor,
+		Timeout:             *timeout,
+	}
+	if *shards > 0 {
+		create.Shards = shards
+	}
+	if *replicas >= 0 {
+		create.Replicas = replicas
+	}
+	if *aliases != "" {
+		create.Aliases = strings.Split(*aliases, ",")
+	}
+
+	mgr, err := indexManager(cn, *settingsFile)
+	if err != nil {
+		return err
+	}
+
+	var mappings interface{}
+	if *mappingsFile != "" {
+		body, err := readInput(*mappingsFile)
+		if err != nil {
+			return err
+		}
+		mappings = json.RawMessage(body)
+	}
+
+	return mgr.Create(ctx, flags.Arg(0), create, mappings)
+}
+
+func indexDelete(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	if len(args) != 1 {
+		return errors.New("Expected an index name")
+	}
+
+	mgr, err := indexManager(cn, "")
+	if err != nil {
+		return err
+	}
+	return mgr.Delete(ctx, args[0])
+}
+
+func indexList(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	mgr, err := indexManager(cn, "")
+	if err != nil {
+		return err
+	}
+
+	names, err := mgr.GetNames(ctx)
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+func indexMakePermanent(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	flags := commandFlags("index make-permanent")
+	settingsFile := flags.String("settings", "", "JSON file with index settings")
+	replicas := flags.Int("replicas", -1, "number of replicas, by default the one the index was meant to have or the template default")
+	waitFor := flags.String("wait-for", "yellow", "index health to wait for, green or yellow")
+	timeout := flags.String("timeout", "30s", "how long to wait for the index health")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("Expected an index name")
+	}
+
+	mgr, err := indexManager(cn, *settingsFile)
+	if err != nil {
+		return err
+	}
+	permanent := esu.PermanentFlags{WaitForStatus: *waitFor, Timeout: *timeout}
+	if *replicas >= 0 {
+		permanent.Replicas = replicas
+	}
+	return mgr.MakePermanent(ctx, flags.Arg(0), permanent)
+}
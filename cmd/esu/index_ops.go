@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/leffen/esu"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	register("index open", command{usage: "<index>", help: "Open a closed index", run: indexOp(esu.IndexManager.Open)})
+	register("index close", command{usage: "<index>", help: "Close an index", run: indexOp(esu.IndexManager.Close)})
+	register("index refresh", command{usage: "<index>", help: "Make the latest changes to an index searchable", run: indexOp(esu.IndexManager.Refresh)})
+	register("index flush", command{usage: "<index>", help: "Commit the latest changes to an index to disk", run: indexOp(esu.IndexManager.Flush)})
+	register("index forcemerge", command{usage: "[flags] <index>", help: "Merge the segments of an index", run: indexForceMerge})
+	register("index shrink", command{usage: "[flags] <source> <target>", help: "Copy an index into a new index with fewer shards", run: indexShrink})
+	register("index rollover", command{usage: "[flags] <alias>", help: "Point an alias at a new index when conditions are met", run: indexRollover})
+	register("index clear-cache", command{usage: "[flags] <index>", help: "Clear the caches of an index", run: indexClearCache})
+}
+
+// indexOp makes a command of an IndexManager method that takes an index name
+func indexOp(op func(esu.IndexManager, context.Context, string) error) func(context.Context, *esu.EsConnection, []string) error {
+	return func(ctx context.Context, cn *esu.EsConnection, args []string) error {
+		if len(args) != 1 {
+			return errors.New("Expected an index name")
+		}
+
+		mgr, err := indexManager(cn, "")
+		if err != nil {
+			return err
+		}
+		return op(mgr, ctx, args[0])
+	}
+}
+
+func indexForceMerge(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	flags := commandFlags("index forcemerge")
+	segments := flags.Int("segments", 0, "number of segments to merge down to, 0 lets Elasticsearch decide")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("Expected an index name")
+	}
+
+	mgr, err := indexManager(cn, "")
+	if err != nil {
+		return err
+	}
+	return mgr.ForceMerge(ctx, flags.Arg(0), *segments)
+}
+
+func indexShrink(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	flags := commandFlags("index shrink")
+	shards := flags.Int("shards", 1, "number of primary shards of the target")
+	aliases := flags.String("aliases", "", "comma separated aliases to add to the target")
+	activeShards := flags.String("wait-for-active-shards", "", "shard copies that must be active before returning, a number or all")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return errors.New("Expected a source and a target index")
+	}
+
+	shrink := esu.ShrinkFlags{Shards: *shards, WaitForActiveShards: *activeShards}
+	if *aliases != "" {
+		shrink.Aliases = strings.Split(*aliases, ",")
+	}
+
+	mgr, err := indexManager(cn, "")
+	if err != nil {
+		return err
+	}
+	return mgr.Shrink(ctx, flags.Arg(0), flags.Arg(1), shrink)
+}
+
+func indexRollover(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	flags := commandFlags("index rollover")
+	newIndex := flags.String("new-index", "", "name of the new index, by default the old name with its number incremented")
+	maxAge := flags.String("max-age", "", "roll over if the index is older, like 7d")
+	maxDocs := flags.Int64("max-docs", 0, "roll over if the index has more documents")
+	maxSize := flags.String("max-size", "", "roll over if the index is larger, like 50gb")
+	dryRun := flags.Bool("dry-run", false, "only check the conditions")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("Expected an alias")
+	}
+
+	mgr, err := indexManager(cn, "")
+	if err != nil {
+		return err
+	}
+	res, err := mgr.Rollover(ctx, flags.Arg(0), esu.RolloverFlags{
+		NewIndex: *newIndex,
+		MaxAge:   *maxAge,
+		MaxDocs:  *maxDocs,
+		MaxSize:  *maxSize,
+		DryRun:   *dryRun,
+	})
+	if err != nil {
+		return err
+	}
+
+	conditions := make([]string, 0, len(res.Conditions))
+	for condition := range res.Conditions {
+		conditions = append(conditions, condition)
+	}
+	sort.Strings(conditions)
+
+	t := esu.NewTable("Condition", "Met")
+	for _, condition := range conditions {
+		t.Add(condition, res.Conditions[condition])
+	}
+	t.Print()
+	switch {
+	case res.DryRun:
+		fmt.Printf("\nDry run, %s would roll over to %s if a condition is met\n", res.OldIndex, res.NewIndex)
+	case res.RolledOver:
+		fmt.Printf("\nRolled over from %s to %s\n", res.OldIndex, res.NewIndex)
+	default:
+		fmt.Printf("\nNot rolled over, %s stays the write index\n", res.OldIndex)
+	}
+	return nil
+}
+
+func indexClearCache(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	flags := commandFlags("index clear-cache")
+	query := flags.Bool("query", false, "clear the query cache")
+	fielddata := flags.Bool("fielddata", false, "clear the fielddata cache")
+	request := flags.Bool("request", false, "clear the request cache")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("Expected an index name")
+	}
+
+	mgr, err := indexManager(cn, "")
+	if err != nil {
+		return err
+	}
+	return mgr.ClearCache(ctx, flags.Arg(0), esu.ClearCacheFlags{Query: *query, Fielddata: *fielddata, Request: *request})
+}
@@ -0,0 +1,114 @@
+// Command esu is a command line tool for inspecting and loading Elasticsearch clusters.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/fatih/color"
+	"github.com/leffen/esu"
+)
+
+// command is a subcommand of esu, e.g. "health" or "index create"
+type command struct {
+	usage string
+	help  string
+	run   func(ctx context.Context, cn *esu.EsConnection, args []string) error
+}
+
+var commands = map[string]command{}
+
+func register(name string, cmd command) {
+	commands[name] = cmd
+}
+
+func main() {
+	flags := flag.NewFlagSet("esu", flag.ExitOnError)
+	profile := flags.String("profile", "", "connection profile, defaults to $ES_PROFILE or the default profile")
+	urls := flags.String("url", "", "comma separated node urls, overrides the profile")
+	version := flags.Bool("version", false, "print the version and exit")
+	flags.Usage = usage(flags)
+	flags.Parse(os.Args[1:])
+
+	if *version {
+		fmt.Println("esu", esu.VERSION)
+		return
+	}
+
+	name, cmd, args := lookup(flags.Args())
+	if name == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	var opts []esu.Option
+	if *urls != "" {
+		opts = append(opts, esu.WithURLs(esu.SplitList(*urls)...))
+	}
+	cn, err := esu.ConnectProfile(*profile, opts...)
+	if err != nil {
+		exitWithError(err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if err := cmd.run(ctx, cn, args); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(2)
+		}
+		exitWithError(err)
+	}
+}
+
+// lookup finds the longest command name matching the start of args
+func lookup(args []string) (string, command, []string) {
+	for n := len(args); n > 0; n-- {
+		name := strings.Join(args[:n], " ")
+		if cmd, ok := commands[name]; ok {
+			return name, cmd, args[n:]
+		}
+	}
+	return "", command{}, nil
+}
+
+func usage(flags *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintf(os.Stderr, "Usage: esu [flags] <command> [args]\n\nCommands:\n")
+
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(os.Stderr, "  %-40s %s\n", strings.TrimSpace(name+" "+commands[name].usage), commands[name].help)
+		}
+
+		fmt.Fprintf(os.Stderr, "\nFlags:\n")
+		flags.PrintDefaults()
+	}
+}
+
+// commandFlags creates the flag set of a subcommand
+func commandFlags(name string) *flag.FlagSet {
+	cmd := commands[name]
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: esu %s %s\n\n%s\n", name, cmd.usage, cmd.help)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+func exitWithError(err error) {
+	txt := color.New(color.FgRed).SprintfFunc()("\nERROR: %v", err)
+	fmt.Fprintln(os.Stderr, txt)
+	os.Exit(1)
+}
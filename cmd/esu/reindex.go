@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/leffen/esu"
+	"github.com/pkg/errors"
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+func init() {
+	register("reindex", command{usage: "[flags] <source index> <target index>", help: "Copy an index, possibly to another cluster", run: reindex})
+}
+
+func reindex(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	flags := commandFlags("reindex")
+	targetProfile := flags.String("target-profile", "", "connection profile of the target cluster")
+	targetURL := flags.String("target-url", "", "comma separated node urls of the target cluster")
+	indexType := flags.String("type", "", "document type in the target index, the source type if empty")
+	query := flags.String("query", "", "JSON query selecting the documents to copy")
+	slices := flags.Int("slices", 1, "scrolls run in parallel")
+	size := flags.Int("size", esu.DefaultExportPageSize, "documents fetched per request")
+	actions := flags.Int("actions", esu.DefaultReindexBulkActions, "documents per bulk request")
+	bulkSize := flags.Int("bulk-size", esu.DefaultReindexBulkSize, "bytes per bulk request")
+	workers := flags.Int("workers", esu.DefaultReindexBulkWorkers, "concurrent bulk requests")
+	deadLetters := flags.String("dead-letters", "", "file to append rejected documents to")
+	noCheck := flags.Bool("no-count-check", false, "don't compare document counts once done")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return errors.New("Expected a source and a target index")
+	}
+
+	// The target is the source cluster unless another one is given
+	target := cn
+	if *targetProfile != "" || *targetURL != "" {
+		var opts []esu.Option
+		if *targetURL != "" {
+			opts = append(opts, esu.WithURLs(esu.SplitList(*targetURL)...))
+		}
+		var err error
+		if target, err = esu.ConnectProfile(*targetProfile, opts...); err != nil {
+			return err
+		}
+	}
+
+	opts := esu.ReindexOptions{
+		Slices:         *slices,
+		PageSize:       *size,
+		Type:           *indexType,
+		BulkActions:    *actions,
+		BulkSize:       *bulkSize,
+		BulkWorkers:    *workers,
+		SkipCountCheck: *noCheck,
+	}
+	if *query != "" {
+		if !json.Valid([]byte(*query)) {
+			return errors.New("Query is not valid JSON")
+		}
+		opts.Query = elastic.NewRawStringQuery(*query)
+	}
+
+	var failures esu.FailureSink
+	if *deadLetters != "" {
+		dl, err := esu.NewDeadLetterFile(*deadLetters)
+		if err != nil {
+			return err
+		}
+		defer dl.Close()
+		failures = dl
+	}
+	opts.Configure = func(pump *esu.Datapump) {
+		pump.Failures = failures
+	}
+
+	res, err := esu.Reindex(ctx, cn, target, flags.Arg(0), flags.Arg(1), opts)
+	if res != nil {
+		printImportStats(res.Stats)
+		if !*noCheck && res.Target > 0 {
+			t := esu.NewTable("Counts", "")
+			t.Add("Source", res.Source)
+			t.Add("Skipped", res.Skipped)
+			t.Add("Target", res.Target)
+			t.Print()
+		}
+	}
+	return err
+}
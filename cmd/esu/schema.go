@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/leffen/esu"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	register("schema plan", command{usage: "<file>", help: "Show how the indices differ from a schema file", run: schemaPlan})
+	register("schema apply", command{usage: "<file>", help: "Make the changes of a schema file that don't need a reindex", run: schemaApply})
+}
+
+// planSchema loads the schema file in args and prints the plan for it
+func planSchema(ctx context.Context, cn *esu.EsConnection, args []string) (esu.IndexManager, *esu.Plan, error) {
+	if len(args) != 1 {
+		return nil, nil, errors.New("Expected a schema file")
+	}
+	schema, err := esu.LoadSchema(args[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mgr, err := indexManager(cn, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	plan, err := esu.PlanSchema(ctx, mgr, schema)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if plan.Empty() {
+		fmt.Println("The indices match the schema.")
+	}
+	for _, c := range plan.Changes {
+		fmt.Println(c)
+	}
+	return mgr, plan, nil
+}
+
+func schemaPlan(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	_, _, err := planSchema(ctx, cn, args)
+	return err
+}
+
+func schemaApply(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	mgr, plan, err := planSchema(ctx, cn, args)
+	if err != nil {
+		return err
+	}
+	if err := esu.ApplyPlan(ctx, mgr, plan); err != nil {
+		return err
+	}
+
+	if reindex := plan.Reindex(); len(reindex) > 0 {
+		return errors.Errorf("%d changes need a reindex and were not applied", len(reindex))
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/leffen/esu"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	register("template put", command{usage: "<name> [file|-]", help: "Create or replace an index template from a JSON file or stdin", run: templatePut})
+	register("template get", command{usage: "<name>", help: "Show an index template", run: templateGet})
+	register("template list", command{help: "List all index templates", run: templateList})
+	register("template delete", command{usage: "<name>", help: "Delete an index template", run: templateDelete})
+}
+
+func templatePut(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New("Expected a template name and an optional file")
+	}
+	var path string
+	if len(args) == 2 {
+		path = args[1]
+	}
+
+	body, err := readInput(path)
+	if err != nil {
+		return err
+	}
+	var tmpl esu.IndexTemplate
+	if err := json.Unmarshal(body, &tmpl); err != nil {
+		return errors.Wrap(err, "Invalid index template JSON")
+	}
+
+	mgr, err := indexManager(cn, "")
+	if err != nil {
+		return err
+	}
+	return mgr.PutTemplate(ctx, args[0], tmpl)
+}
+
+func templateGet(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	if len(args) != 1 {
+		return errors.New("Expected a template name")
+	}
+
+	mgr, err := indexManager(cn, "")
+	if err != nil {
+		return err
+	}
+	tmpl, err := mgr.GetTemplate(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if tmpl == nil {
+		return errors.Errorf("Index template %q does not exist", args[0])
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(tmpl)
+}
+
+func templateList(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	mgr, err := indexManager(cn, "")
+	if err != nil {
+		return err
+	}
+
+	names, err := mgr.GetTemplateNames(ctx)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+func templateDelete(ctx context.Context, cn *esu.EsConnection, args []string) error {
+	if len(args) != 1 {
+		return errors.New("Expected a template name")
+	}
+
+	mgr, err := indexManager(cn, "")
+	if err != nil {
+		return err
+	}
+	return mgr.DeleteTemplate(ctx, args[0])
+}
@@ -0,0 +1,280 @@
+package esu
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+// Option configures a connection created by Connect
+type Option func(*connectConfig) error
+
+type connectConfig struct {
+	urls     []string
+	settings ConnectionSettings
+
+	username string
+	password string
+	headers  http.Header
+
+	tls      *tls.Config
+	caFiles  []string
+	certFile string
+	keyFile  string
+	insecure bool
+
+	timeout time.Duration
+}
+
+// Connect creates a ES connection object from options. Without WithURLs it
+// connects to http://127.0.0.1:9200.
+func Connect(opts ...Option) (*EsConnection, error) {
+	cfg := connectConfig{headers: http.Header{}}
+	for _, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			return nil, err
+		}
+	}
+	if len(cfg.urls) == 0 {
+		cfg.urls = []string{elastic.DefaultURL}
+	}
+
+	connection := EsConnection{}
+	for _, uri := range cfg.urls {
+		u, err := url.Parse(uri)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid Elasticsearch url %q", uri)
+		}
+		connection.URLs = append(connection.URLs, u)
+	}
+
+	connection.URL = connection.URLs[0]
+	connection.Scheme = connection.URL.Scheme
+	connection.Host = connection.URL.Hostname()
+	connection.Port = connection.URL.Port()
+
+	httpClient, err := cfg.httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	clientOpts := []elastic.ClientOptionFunc{
+		elastic.SetURL(cfg.urls...),
+		elastic.SetSniff(cfg.settings.Sniff),
+		elastic.SetHealthcheck(cfg.settings.Healthcheck),
+		elastic.SetHttpClient(httpClient),
+	}
+	if cfg.username != "" {
+		clientOpts = append(clientOpts, elastic.SetBasicAuth(cfg.username, cfg.password))
+	}
+
+	client, err := elastic.NewClient(clientOpts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not connect to %s", strings.Join(cfg.urls, ", "))
+	}
+	connection.Client = client
+
+	return &connection, nil
+}
+
+func (cfg *connectConfig) httpClient() (*http.Client, error) {
+	tlsConfig := cfg.tls
+	if tlsConfig == nil && (len(cfg.caFiles) > 0 || cfg.certFile != "" || cfg.insecure) {
+		tlsConfig = &tls.Config{}
+	}
+
+	if tlsConfig != nil {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.InsecureSkipVerify = tlsConfig.InsecureSkipVerify || cfg.insecure
+
+		if len(cfg.caFiles) > 0 {
+			if tlsConfig.RootCAs == nil {
+				tlsConfig.RootCAs = x509.NewCertPool()
+			}
+			for _, path := range cfg.caFiles {
+				pem, err := os.ReadFile(path)
+				if err != nil {
+					return nil, errors.Wrapf(err, "Unable to read CA certificate %q", path)
+				}
+				if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+					return nil, errors.Errorf("No certificates found in %q", path)
+				}
+			}
+		}
+
+		if cfg.certFile != "" {
+			cert, err := tls.LoadX509KeyPair(cfg.certFile, cfg.keyFile)
+			if err != nil {
+				return nil, errors.Wrap(err, "Unable to load client certificate")
+			}
+			tlsConfig.Certificates = append(tlsConfig.Certificates, cert)
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	var rt http.RoundTripper = transport
+	if len(cfg.headers) > 0 {
+		rt = &headerTransport{headers: cfg.headers, next: transport}
+	}
+
+	return &http.Client{Transport: rt, Timeout: cfg.timeout}, nil
+}
+
+// headerTransport adds headers to every request, including the pings and
+// sniffs the elastic client sends on its own
+type headerTransport struct {
+	headers http.Header
+	next    http.RoundTripper
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, values := range t.headers {
+		req.Header[name] = values
+	}
+	return t.next.RoundTrip(req)
+}
+
+// WithURLs sets the seed node urls, replacing any set before
+func WithURLs(uris ...string) Option {
+	return func(cfg *connectConfig) error {
+		cfg.urls = uris
+		return nil
+	}
+}
+
+// WithSettings sets how the client finds and checks the nodes of the cluster
+func WithSettings(settings ConnectionSettings) Option {
+	return func(cfg *connectConfig) error {
+		cfg.settings = settings
+		return nil
+	}
+}
+
+// WithBasicAuth authenticates every request with username and password
+func WithBasicAuth(username, password string) Option {
+	return func(cfg *connectConfig) error {
+		cfg.username, cfg.password = username, password
+		return nil
+	}
+}
+
+// WithAPIKey authenticates every request with an API key, given as the
+// base64 encoded "id:api_key" Elasticsearch returns
+func WithAPIKey(key string) Option {
+	return WithHeader("Authorization", "ApiKey "+key)
+}
+
+// WithHeader sets a header on every request, replacing any earlier value
+func WithHeader(name, value string) Option {
+	return func(cfg *connectConfig) error {
+		cfg.headers.Set(name, value)
+		return nil
+	}
+}
+
+// WithCACert trusts the PEM encoded CA certificates in the file at path
+func WithCACert(path string) Option {
+	return func(cfg *connectConfig) error {
+		cfg.caFiles = append(cfg.caFiles, path)
+		return nil
+	}
+}
+
+// WithClientCert authenticates with the PEM encoded certificate and key files
+func WithClientCert(certFile, keyFile string) Option {
+	return func(cfg *connectConfig) error {
+		cfg.certFile, cfg.keyFile = certFile, keyFile
+		return nil
+	}
+}
+
+// WithInsecureSkipVerify disables verification of the server certificate
+func WithInsecureSkipVerify() Option {
+	return func(cfg *connectConfig) error {
+		cfg.insecure = true
+		return nil
+	}
+}
+
+// WithTLSConfig sets the base TLS configuration. CA and client certificates
+// given through other options are added to it.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(cfg *connectConfig) error {
+		cfg.tls = tlsConfig
+		return nil
+	}
+}
+
+// WithTimeout limits the time of every request, including reading the response
+func WithTimeout(timeout time.Duration) Option {
+	return func(cfg *connectConfig) error {
+		cfg.timeout = timeout
+		return nil
+	}
+}
+
+// FromEnv configures the connection from environment variables. Variables
+// that aren't set leave the options given before untouched.
+//
+//	ES_URLS                         comma separated seed node urls
+//	ES_PROTOCOL, ES_HOST, ES_PORT   a single node, if ES_URLS isn't set
+//	ES_USERNAME, ES_PASSWORD        basic auth credentials
+//	ES_API_KEY                      API key
+//	ES_CA_CERT                      CA certificate file
+//	ES_CLIENT_CERT, ES_CLIENT_KEY   client certificate and key files
+//	ES_TIMEOUT                      request timeout, e.g. "30s"
+func FromEnv() Option {
+	return func(cfg *connectConfig) error {
+		if urls := SplitList(EnvGetWithDefault("ES_URLS", "")); len(urls) > 0 {
+			cfg.urls = urls
+		} else if host := EnvGetWithDefault("ES_HOST", ""); host != "" {
+			u := getConnectionURL(EnvGetWithDefault("ES_PROTOCOL", "http"), host, EnvGetWithDefault("ES_PORT", "9200"))
+			cfg.urls = []string{u.String()}
+		}
+
+		if username := EnvGetWithDefault("ES_USERNAME", ""); username != "" {
+			cfg.username = username
+			cfg.password = EnvGetWithDefault("ES_PASSWORD", "")
+		}
+		if key := EnvGetWithDefault("ES_API_KEY", ""); key != "" {
+			cfg.headers.Set("Authorization", "ApiKey "+key)
+		}
+		if ca := EnvGetWithDefault("ES_CA_CERT", ""); ca != "" {
+			cfg.caFiles = append(cfg.caFiles, ca)
+		}
+		if cert := EnvGetWithDefault("ES_CLIENT_CERT", ""); cert != "" {
+			cfg.certFile = cert
+			cfg.keyFile = EnvGetWithDefault("ES_CLIENT_KEY", "")
+		}
+		if timeout := EnvGetWithDefault("ES_TIMEOUT", ""); timeout != "" {
+			d, err := time.ParseDuration(timeout)
+			if err != nil {
+				return errors.Wrap(err, "Invalid ES_TIMEOUT")
+			}
+			cfg.timeout = d
+		}
+		return nil
+	}
+}
+
+// SplitList splits a comma separated list such as ES_URLS, trimming entries
+// and dropping empty ones
+func SplitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,82 @@
+package esu
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConnect(t *testing.T) {
+	var got *http.Request
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"node-1","cluster_name":"test","version":{"number":"5.5.2"}}`))
+	}))
+	defer srv.Close()
+
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(ca, cert, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("ES_URLS", srv.URL)
+	t.Setenv("ES_USERNAME", "elastic")
+	t.Setenv("ES_PASSWORD", "secret")
+	t.Setenv("ES_CA_CERT", ca)
+
+	connection, err := Connect(
+		WithURLs("http://127.0.0.1:1"),
+		FromEnv(),
+		WithHeader("X-Opaque-Id", "esu-test"),
+		WithTimeout(time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if connection.URL.String() != srv.URL {
+		t.Errorf("expected url from the environment, got %s", connection.URL)
+	}
+
+	if _, err := connection.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if user, pass, ok := got.BasicAuth(); !ok || user != "elastic" || pass != "secret" {
+		t.Errorf("expected basic auth from the environment, got %q %q", user, pass)
+	}
+	if got.Header.Get("X-Opaque-Id") != "esu-test" {
+		t.Errorf("expected custom header, got %v", got.Header)
+	}
+}
+
+func TestFromEnv_URLs(t *testing.T) {
+	t.Setenv("ES_URLS", " http://a:9200, http://b:9200 ,,")
+
+	var cfg connectConfig
+	if err := FromEnv()(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.urls) != 2 || cfg.urls[0] != "http://a:9200" || cfg.urls[1] != "http://b:9200" {
+		t.Errorf("expected trimmed urls, got %q", cfg.urls)
+	}
+}
+
+func TestWithAPIKey_ReplacesEnv(t *testing.T) {
+	t.Setenv("ES_API_KEY", "from-env")
+
+	cfg := connectConfig{headers: http.Header{}}
+	for _, opt := range []Option{FromEnv(), WithAPIKey("explicit")} {
+		if err := opt(&cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := cfg.headers["Authorization"]; len(got) != 1 || got[0] != "ApiKey explicit" {
+		t.Errorf("expected a single explicit api key, got %q", got)
+	}
+}
@@ -0,0 +1,335 @@
+package esu
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ColumnType is the type the values of a CSV column are converted to
+type ColumnType string
+
+// Column types, from the most to the least specific
+const (
+	ColumnInteger ColumnType = "integer"
+	ColumnFloat   ColumnType = "float"
+	ColumnBoolean ColumnType = "boolean"
+	ColumnDate    ColumnType = "date"
+	ColumnString  ColumnType = "string"
+)
+
+// DefaultInferRows is how many rows are sampled to infer the column types
+const DefaultInferRows = 100
+
+// DefaultDateLayouts are the date formats recognized when inferring column types
+var DefaultDateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// CSVReader turns the rows of a CSV or TSV file into PumpData records. The
+// first row names the fields, and the column types are inferred from the
+// first rows unless given in Types. Gzip compressed input is detected and decompressed.
+type CSVReader struct {
+	// Types sets the type of columns by name. Other columns are inferred.
+	Types map[string]ColumnType
+
+	// IDColumn names the column holding the document id.
+	// If empty, Elasticsearch generates the ids.
+	IDColumn string
+
+	// InferRows is how many rows are sampled to infer types, DefaultInferRows if not set
+	InferRows int
+
+	// DateLayouts are the recognized date formats, DefaultDateLayouts if not set.
+	// Dates are sent to Elasticsearch in RFC 3339 format.
+	DateLayouts []string
+
+	// OnMalformed, if set, is called for every row with the wrong number of
+	// fields or a value that doesn't match the column type. Such rows are
+	// skipped, and logged if not set.
+	OnMalformed func(line *MalformedLine)
+
+	// Rows and Malformed count the data rows read and skipped
+	Rows      int
+	Malformed int
+
+	r       *csv.Reader
+	header  []string
+	types   []ColumnType
+	sample  []csvRow
+	started bool
+}
+
+// csvRow is a row of input with the line it started on
+type csvRow struct {
+	fields []string
+	line   int
+	err    error
+}
+
+// NewCSVReader creates a reader of comma separated values
+func NewCSVReader(r io.Reader) (*CSVReader, error) {
+	return newCSVReader(r, ',')
+}
+
+// NewTSVReader creates a reader of tab separated values
+func NewTSVReader(r io.Reader) (*CSVReader, error) {
+	return newCSVReader(r, '\t')
+}
+
+func newCSVReader(r io.Reader, comma rune) (*CSVReader, error) {
+	br, err := maybeGunzip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	cr := csv.NewReader(br)
+	cr.Comma = comma
+	if comma == '\t' {
+		cr.LazyQuotes = true
+	}
+	return &CSVReader{r: cr}, nil
+}
+
+// Schema reads the header and the sample rows, and returns the type of every column
+func (cr *CSVReader) Schema() (map[string]ColumnType, error) {
+	if err := cr.start(); err != nil {
+		return nil, err
+	}
+
+	schema := make(map[string]ColumnType, len(cr.header))
+	for i, name := range cr.header {
+		schema[name] = cr.types[i]
+	}
+	return schema, nil
+}
+
+// Mapping returns index mappings for the columns, to pass to IndexManager.Create
+func (cr *CSVReader) Mapping(indexType string) (map[string]interface{}, error) {
+	schema, err := cr.Schema()
+	if err != nil {
+		return nil, err
+	}
+
+	properties := jsonMap{}
+	for name, typ := range schema {
+		switch typ {
+		case ColumnInteger:
+			properties[name] = jsonMap{"type": "long"}
+		case ColumnFloat:
+			properties[name] = jsonMap{"type": "double"}
+		case ColumnBoolean:
+			properties[name] = jsonMap{"type": "boolean"}
+		case ColumnDate:
+			properties[name] = jsonMap{"type": "date"}
+		default:
+			// The same as Elasticsearch maps strings dynamically
+			properties[name] = jsonMap{
+				"type":   "text",
+				"fields": jsonMap{"keyword": jsonMap{"type": "keyword", "ignore_above": 256}},
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		indexType: jsonMap{"properties": properties},
+	}, nil
+}
+
+// Pump sends a record for every row on lc until the input is exhausted or
+// ctx is done. It does not send an EOF record.
+func (cr *CSVReader) Pump(ctx context.Context, lc chan<- PumpData) error {
+	if err := cr.start(); err != nil {
+		return err
+	}
+
+	for {
+		var row csvRow
+		if len(cr.sample) > 0 {
+			row, cr.sample = cr.sample[0], cr.sample[1:]
+		} else {
+			var err error
+			if row, err = cr.read(); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+		cr.Rows++
+
+		err := row.err
+		var data PumpData
+		if err == nil {
+			data, err = cr.record(row.fields)
+		}
+		if err != nil {
+			cr.malformed(&MalformedLine{Line: row.line, Text: strings.Join(row.fields, string(cr.r.Comma)), Err: err})
+			continue
+		}
+
+		select {
+		case lc <- data:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// start reads the header and the sample rows, and infers the column types
+func (cr *CSVReader) start() error {
+	if cr.started {
+		return nil
+	}
+	cr.started = true
+
+	header, err := cr.r.Read()
+	if err != nil {
+		return errors.Wrap(err, "Unable to read CSV header")
+	}
+	cr.header = header
+
+	n := cr.InferRows
+	if n <= 0 {
+		n = DefaultInferRows
+	}
+	for len(cr.sample) < n {
+		row, err := cr.read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		cr.sample = append(cr.sample, row)
+	}
+
+	cr.types = make([]ColumnType, len(header))
+	for i, name := range header {
+		if typ, ok := cr.Types[name]; ok {
+			cr.types[i] = typ
+		} else {
+			cr.types[i] = cr.inferType(i)
+		}
+	}
+	return nil
+}
+
+// inferType picks the most specific type all sampled values of column i match
+func (cr *CSVReader) inferType(i int) ColumnType {
+	candidates := []ColumnType{ColumnInteger, ColumnFloat, ColumnBoolean, ColumnDate}
+	seen := false
+
+	for _, row := range cr.sample {
+		if row.err != nil || i >= len(row.fields) || row.fields[i] == "" {
+			continue
+		}
+		seen = true
+
+		var left []ColumnType
+		for _, typ := range candidates {
+			if _, err := cr.convert(typ, row.fields[i]); err == nil {
+				left = append(left, typ)
+			}
+		}
+		candidates = left
+	}
+
+	if !seen || len(candidates) == 0 {
+		return ColumnString
+	}
+	return candidates[0]
+}
+
+// read reads the next row. Rows that can't be parsed are returned with the
+// error set, so that they are reported as malformed in order.
+func (cr *CSVReader) read() (csvRow, error) {
+	fields, err := cr.r.Read()
+	if err == io.EOF {
+		return csvRow{}, err
+	}
+	if err != nil {
+		if perr, ok := err.(*csv.ParseError); ok {
+			return csvRow{fields: fields, line: perr.StartLine, err: perr.Err}, nil
+		}
+		return csvRow{}, errors.Wrap(err, "Unable to read input")
+	}
+
+	line, _ := cr.r.FieldPos(0)
+	return csvRow{fields: fields, line: line}, nil
+}
+
+func (cr *CSVReader) record(row []string) (PumpData, error) {
+	if len(row) != len(cr.header) {
+		return PumpData{}, errors.Errorf("expected %d fields, got %d", len(cr.header), len(row))
+	}
+
+	var data PumpData
+	doc := make(map[string]interface{}, len(row))
+	for i, value := range row {
+		name := cr.header[i]
+		if name == cr.IDColumn {
+			data.UID = value
+		}
+		if value == "" {
+			continue
+		}
+
+		v, err := cr.convert(cr.types[i], value)
+		if err != nil {
+			return PumpData{}, errors.Wrapf(err, "column %q", name)
+		}
+		doc[name] = v
+	}
+
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return PumpData{}, err
+	}
+	data.JSON = string(body)
+	return data, nil
+}
+
+func (cr *CSVReader) convert(typ ColumnType, value string) (interface{}, error) {
+	switch typ {
+	case ColumnInteger:
+		return strconv.ParseInt(value, 10, 64)
+	case ColumnFloat:
+		return strconv.ParseFloat(value, 64)
+	case ColumnBoolean:
+		return strconv.ParseBool(strings.ToLower(value))
+	case ColumnDate:
+		layouts := cr.DateLayouts
+		if len(layouts) == 0 {
+			layouts = DefaultDateLayouts
+		}
+		for _, layout := range layouts {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t.Format(time.RFC3339Nano), nil
+			}
+		}
+		return nil, errors.Errorf("%q is not a date", value)
+	case ColumnString, "":
+		return value, nil
+	default:
+		return nil, errors.Errorf("unknown column type %q", typ)
+	}
+}
+
+func (cr *CSVReader) malformed(line *MalformedLine) {
+	cr.Malformed++
+	if cr.OnMalformed != nil {
+		cr.OnMalformed(line)
+		return
+	}
+	log.Warnf("Skipping malformed %s", line)
+}
@@ -0,0 +1,109 @@
+package esu
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCSVReader(t *testing.T) {
+	input := strings.Join([]string{
+		"id,count,price,active,created,name,zip",
+		"1,10,1.5,true,2019-03-01,first,0123",
+		"2,,2,FALSE,2019-03-02 10:00:00,second,4567",
+		"3,x,3",
+		"4,7,0.25,true,2019-03-04T08:30:00Z,\"fourth, last\",8910",
+	}, "\n")
+
+	cr, err := NewCSVReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr.IDColumn = "id"
+	cr.Types = map[string]ColumnType{"zip": ColumnString}
+
+	schema, err := cr.Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]ColumnType{
+		"id":      ColumnInteger,
+		"count":   ColumnInteger,
+		"price":   ColumnFloat,
+		"active":  ColumnBoolean,
+		"created": ColumnDate,
+		"name":    ColumnString,
+		"zip":     ColumnString,
+	}
+	if !reflect.DeepEqual(schema, expected) {
+		t.Errorf("expected schema %v, got %v", expected, schema)
+	}
+
+	var malformed []int
+	cr.OnMalformed = func(line *MalformedLine) {
+		malformed = append(malformed, line.Line)
+	}
+
+	lc := make(chan PumpData, 10)
+	if err := cr.Pump(context.Background(), lc); err != nil {
+		t.Fatal(err)
+	}
+	close(lc)
+
+	var docs []map[string]interface{}
+	var ids []string
+	for data := range lc {
+		var doc map[string]interface{}
+		if err := json.Unmarshal([]byte(data.JSON), &doc); err != nil {
+			t.Fatal(err)
+		}
+		docs = append(docs, doc)
+		ids = append(ids, data.UID)
+	}
+
+	if strings.Join(ids, ",") != "1,2,4" {
+		t.Errorf("expected ids 1,2,4, got %v", ids)
+	}
+	if cr.Rows != 4 || cr.Malformed != 1 || len(malformed) != 1 || malformed[0] != 4 {
+		t.Errorf("expected line 4 to be malformed, got %v of %d rows", malformed, cr.Rows)
+	}
+	if len(docs) != 3 {
+		t.Fatalf("expected 3 documents, got %d", len(docs))
+	}
+
+	second := map[string]interface{}{
+		"id": 2.0, "price": 2.0, "active": false,
+		"created": "2019-03-02T10:00:00Z", "name": "second", "zip": "4567",
+	}
+	if !reflect.DeepEqual(docs[1], second) {
+		t.Errorf("expected %v, got %v", second, docs[1])
+	}
+	if docs[0]["count"] != 10.0 || docs[0]["zip"] != "0123" || docs[2]["name"] != "fourth, last" {
+		t.Errorf("unexpected documents %v", docs)
+	}
+}
+
+func TestCSVReader_Mapping(t *testing.T) {
+	input := "n\tat\tlabel\n1\t2019-03-01\ta\n2\t2019-03-02\tb\n"
+
+	cr, err := NewTSVReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mapping, err := cr.Mapping("doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := json.Marshal(mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"doc":{"properties":{"at":{"type":"date"},"label":{"fields":{"keyword":{"ignore_above":256,"type":"keyword"}},"type":"text"},"n":{"type":"long"}}}}`
+	if string(body) != expected {
+		t.Errorf("expected %s, got %s", expected, body)
+	}
+}
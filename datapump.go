@@ -2,19 +2,71 @@ package esu
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 
 	elastic "gopkg.in/olivere/elastic.v5"
 )
 
-// PumpData is the way to communicate between channels
+// PumpOp is the bulk operation a PumpData record is sent as
+type PumpOp int
+
+const (
+	// OpIndex indexes JSON as the document, replacing any existing one
+	OpIndex PumpOp = iota
+	// OpCreate indexes JSON, failing if the document already exists
+	OpCreate
+	// OpUpdate merges JSON into the existing document
+	OpUpdate
+	// OpUpsert merges JSON into the document, creating it if it doesn't exist
+	OpUpsert
+	// OpScript updates the document with Script, using JSON as the upsert document if set
+	OpScript
+	// OpDelete deletes the document
+	OpDelete
+)
+
+var pumpOpNames = map[PumpOp]string{
+	OpIndex:  "index",
+	OpCreate: "create",
+	OpUpdate: "update",
+	OpUpsert: "upsert",
+	OpScript: "script",
+	OpDelete: "delete",
+}
+
+func (op PumpOp) String() string {
+	if name, ok := pumpOpNames[op]; ok {
+		return name
+	}
+	return fmt.Sprintf("PumpOp(%d)", int(op))
+}
+
+// PumpData is the way to communicate between channels. A record is either
+// given as JSON, or as a Go value in Rec that the Datapump encodes.
 type PumpData struct {
 	IsEOF bool
 	Rec   interface{}
 	UID   string
 	JSON  string
+
+	// Index overrides the index of the Datapump for this record
+	Index string
+	// Type overrides the document type of the Datapump for this record
+	Type string
+
+	Op          PumpOp
+	Script      *elastic.Script
+	Routing     string
+	Parent      string
+	Version     int64
+	VersionType string
 }
 
 // Datapump - Wrapping structure for connection and index information
@@ -23,10 +75,45 @@ type Datapump struct {
 	Index       string
 	IndexType   string
 	BulkActions int
-	BulkSize    int
+	BulkSize    int // bytes per bulk request, 0 or less for no limit
 	BulkWorkers int
+
+	// FlushInterval, if set, commits pending documents at least this often
+	FlushInterval time.Duration
+
+	// Failures, if set, receives every document Elasticsearch rejected, and
+	// every record that could not be encoded or given an index
+	Failures FailureSink
+
+	// Retry, if set, resends documents rejected with a retryable status
+	Retry *RetryPolicy
+
+	// ResolveIndex, if set, picks the index of records that don't set one.
+	// It may return ErrSkipDocument to leave a record out.
+	ResolveIndex IndexResolver
+
+	// Encoder serializes records given as Rec, json.Marshal if not set
+	Encoder Encoder
+
+	// ExtractID derives the UID of records given as Rec, TagID if not set
+	ExtractID IDExtractor
+
+	// OnProgress, if set, is called with the stats of a run every
+	// ProgressInterval, and once more when the run has finished
+	OnProgress       func(PumpStats)
+	ProgressInterval time.Duration
+
+	// OnCommit, if set, is called after every bulk request. It may be
+	// called concurrently from several bulk workers.
+	OnCommit func(BulkCommit)
+
+	mu  sync.Mutex
+	run *pumpRun
 }
 
+// IndexResolver returns the index a record should be sent to
+type IndexResolver func(data PumpData) (string, error)
+
 // NewDatapump - Creates a new datapump
 func NewDatapump(cn *EsConnection, index, indexType string, bulkActions, bulkSize, bulkWorkers int) *Datapump {
 	pmp := Datapump{
@@ -41,96 +128,306 @@ func NewDatapump(cn *EsConnection, index, indexType string, bulkActions, bulkSiz
 	return &pmp
 }
 
-// Listen for data to send to elastic
-func (pump *Datapump) Listen(lc chan PumpData, ec chan int) {
+// Listen for data to send to elastic. The outcome is sent on ec once the
+// EOF record has been processed, nil on success.
+func (pump *Datapump) Listen(lc chan PumpData, ec chan error) {
+	ec <- pump.Run(context.Background(), lc)
+}
 
-	ctx := context.Background()
+// Run sends records from lc to elastic until an EOF record arrives, lc is
+// closed or ctx is done. Records already queued are flushed and the refresh
+// interval is restored before returning. A *RunError is returned if the run
+// was interrupted or any document failed.
+func (pump *Datapump) Run(ctx context.Context, lc <-chan PumpData) error {
 	client := pump.Connection.Client
 
-	log.Debug("Datapump.listen index= ", pump.Index, " index type= ", pump.IndexType)
-
-	var indices []string
-	indices = append(indices, pump.Index)
+	log.Debug("Datapump.Run index= ", pump.Index, " index type= ", pump.IndexType)
 
-	exists, err := elastic.NewIndicesExistsService(client).Index(indices).Do(ctx)
-	if err != nil {
-		log.Fatal(err)
+	// Indices are prepared the first time a record targets them, and the
+	// refresh interval each of them had is restored at the end
+	prepared := map[string]string{}
+	resetRefreshIntervals := func() error {
+		var err error
+		for index, interval := range prepared {
+			if rerr := pump.resetRefreshInterval(index, interval); rerr != nil && err == nil {
+				err = rerr
+			}
+		}
+		return err
 	}
-
-	if !exists {
-		_, err := client.CreateIndex(pump.Index).Do(ctx)
+	prepare := func(index string) error {
+		if _, ok := prepared[index]; ok {
+			return nil
+		}
+		interval, err := pump.prepareIndex(ctx, index)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
-		log.Printf("created index %s\n", pump.Index)
+		prepared[index] = interval
+		return nil
 	}
 
-	pump.setRefreshInterval(pump.Index, "-1")
+	if pump.ResolveIndex == nil && pump.Index != "" {
+		if err := prepare(pump.Index); err != nil {
+			return err
+		}
+	}
 
-	rows := 0
+	run := newPumpRun(ctx)
+	pump.mu.Lock()
+	pump.run = run
+	pump.mu.Unlock()
 
+	// The processor gets its own context, so that a cancelled run can still
+	// flush what has already been queued.
 	p, err := client.BulkProcessor().
 		Name("ESUImporter").
 		BulkActions(pump.BulkActions).
-		BulkSize(1000000000).
+		BulkSize(bulkLimit(pump.BulkSize)).
+		FlushInterval(pump.FlushInterval).
 		Workers(pump.BulkWorkers).
 		Stats(true).
+		Before(func(executionId int64, requests []elastic.BulkableRequest) {
+			atomic.AddInt64(&run.inFlight, 1)
+			run.commits.Store(executionId, time.Now())
+		}).
 		After(func(executionId int64, requests []elastic.BulkableRequest, response *elastic.BulkResponse, err error) {
-			if err != nil {
-				log.Errorf("Bulk error %s\n", err)
+			commit := BulkCommit{Docs: len(requests), Err: err}
+			if start, ok := run.commits.Load(executionId); ok {
+				commit.Duration = time.Since(start.(time.Time))
+				run.commits.Delete(executionId)
+			}
+			atomic.AddInt64(&run.inFlight, -1)
+
+			pump.afterCommit(run, requests, response, &commit)
+			if pump.OnCommit != nil {
+				pump.OnCommit(commit)
 			}
 		}).
-		Do(ctx)
+		Do(context.Background())
+	if err != nil {
+		resetRefreshIntervals()
+		return errors.Wrap(err, "Unable to start bulk processor")
+	}
+	run.setProcessor(p)
+
+	stopProgress := make(chan struct{})
+	if pump.OnProgress != nil {
+		interval := pump.ProgressInterval
+		if interval <= 0 {
+			interval = DefaultProgressInterval
+		}
+		go run.reportProgress(pump.OnProgress, interval, stopProgress)
+	}
+
+	runErr := pump.feed(ctx, lc, p, run, prepare)
+
+	log.Infoln("Flushing the index")
+	if err := p.Close(); err != nil && runErr == nil {
+		runErr = errors.Wrap(err, "Bulk insert close")
+	}
+	close(stopProgress)
+
+	if err := resetRefreshIntervals(); err != nil && runErr == nil {
+		runErr = err
+	}
 
+	stats := run.stats()
+	printBulkStats(stats.Bulk)
+	if pump.OnProgress != nil {
+		pump.OnProgress(stats)
+	}
+
+	if runErr != nil || stats.Failed > 0 {
+		return &RunError{Rows: int(stats.Rows), Failed: stats.Failed, Err: runErr}
+	}
+	return nil
+}
+
+// afterCommit retries and reports the documents a bulk commit failed on,
+// and fills in the outcome of commit
+func (pump *Datapump) afterCommit(run *pumpRun, requests []elastic.BulkableRequest, response *elastic.BulkResponse, commit *BulkCommit) {
+	if commit.Err != nil {
+		log.Errorf("Bulk error %s\n", commit.Err)
+		commit.Failed = len(requests)
+		atomic.AddInt64(&run.requestFailed, int64(len(requests)))
+		if pump.Failures != nil {
+			reportRequestError(pump.Failures, requests, commit.Err)
+		}
+		return
+	}
+
+	failures := bulkFailures(requests, response)
+
+	if len(failures) > 0 && pump.Retry != nil {
+		var recovered int
+		failures, commit.Retried, recovered = pump.Retry.retry(run.ctx, pump.Connection.Client, failures)
+		log.Debugf("Retried %d documents, %d recovered", commit.Retried, recovered)
+		atomic.AddInt64(&run.retried, int64(commit.Retried))
+		atomic.AddInt64(&run.recovered, int64(recovered))
+	}
+
+	commit.Failed = len(failures)
+	commit.Succeeded = len(requests) - commit.Failed
+
+	if pump.Failures != nil {
+		reportFailures(pump.Failures, failures)
+	}
+}
+
+// prepareIndex creates index if it doesn't exist and disables its refresh
+// interval. The interval the index had is returned, empty if it used the default.
+func (pump *Datapump) prepareIndex(ctx context.Context, index string) (string, error) {
+	client := pump.Connection.Client
+
+	exists, err := client.IndexExists(index).Do(ctx)
 	if err != nil {
-		log.Fatal(err)
+		return "", errors.Wrapf(err, "Could not check if index %q exists", index)
+	}
+
+	var interval string
+	if exists {
+		interval, err = pump.refreshInterval(ctx, index)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		_, err := client.CreateIndex(index).Do(ctx)
+		if err != nil && !IsElasticErrorOfType(err, "index_already_exists_exception") {
+			return "", errors.Wrapf(err, "Unable to create index %q", index)
+		}
+		log.Printf("created index %s\n", index)
 	}
 
+	return interval, pump.setRefreshInterval(ctx, index, "-1")
+}
+
+// indexFor returns the index a record is sent to
+func (pump *Datapump) indexFor(data PumpData) (string, error) {
+	if data.Index != "" {
+		return data.Index, nil
+	}
+	if pump.ResolveIndex != nil {
+		return pump.ResolveIndex(data)
+	}
+	return pump.Index, nil
+}
+
+func (pump *Datapump) feed(ctx context.Context, lc <-chan PumpData, p *elastic.BulkProcessor, run *pumpRun, prepare func(index string) error) error {
 	for {
-		data := <-lc
-		if data.IsEOF {
+		var data PumpData
+		var ok bool
+		select {
+		case <-ctx.Done():
+			log.Infoln("Datapump cancelled after ", atomic.LoadInt64(&run.rows), " rows")
+			return ctx.Err()
+		case data, ok = <-lc:
+		}
+
+		if !ok || data.IsEOF {
 			log.Infoln("Finished signal received ")
-			break
+			return nil
 		}
-		req := elastic.NewBulkIndexRequest().Index(pump.Index).Type(pump.IndexType).Id(data.UID).Doc(data.JSON)
-		p.Add(req)
-		//	fmt.Println(req.Source())
 
-		rows++
-		if rows%100000 == 0 {
-			log.Debugln("Datapump", rows)
-			err = p.Flush()
-			if err != nil {
-				log.Fatal(err)
+		data, err := pump.encode(data)
+		var index string
+		if err == nil {
+			if index, err = pump.indexFor(data); err != nil {
+				err = errors.Wrapf(err, "Unable to resolve index of record %q", data.UID)
 			}
 		}
+		if err != nil {
+			pump.rejectRecord(run, data, err)
+			continue
+		}
+		if err := prepare(index); err != nil {
+			return err
+		}
 
+		p.Add(pump.bulkRequest(index, data))
+
+		atomic.AddInt64(&run.bytes, int64(len(data.JSON)))
+		if rows := atomic.AddInt64(&run.rows, 1); rows%100000 == 0 {
+			log.Debugln("Datapump", rows)
+		}
 	}
-	time.Sleep(2 * time.Second)
+}
 
-	log.Infoln("Flushing the index")
-	err = p.Flush()
-	if err != nil {
-		log.Fatal(err)
+// rejectRecord reports a record that could not be turned into a bulk request
+// to the failure sink, unless it was left out with ErrSkipDocument
+func (pump *Datapump) rejectRecord(run *pumpRun, data PumpData, err error) {
+	atomic.AddInt64(&run.rows, 1)
+	if errors.Cause(err) == ErrSkipDocument {
+		log.Debugf("Skipped record %q", data.UID)
+		return
 	}
-	p.Stop()
 
-	log.Infoln("Resetting resfresh interval to 1s")
-	pump.setRefreshInterval(pump.Index, "1s")
+	log.Warningf("Rejected record %q: %s", data.UID, err)
+	atomic.AddInt64(&run.rejected, 1)
+	if pump.Failures == nil {
+		return
+	}
 
-	printBulkStats(p)
+	doc := FailedDocument{Index: data.Index, Type: pump.typeFor(data), UID: data.UID}
+	if data.JSON != "" || data.Op == OpDelete {
+		// Encoded, so the record can be kept as the request it would have been
+		doc = failedDocument(pump.bulkRequest(data.Index, data))
+	}
+	doc.Error = "record_rejected"
+	doc.Reason = err.Error()
+	sendFailure(pump.Failures, doc)
+}
 
-	err = p.Close()
-	if err != nil {
-		log.Errorln("Bulk insert close", err)
+// bulkLimit maps an unset size limit to -1, which the bulk processor treats as unlimited
+func bulkLimit(n int) int {
+	if n <= 0 {
+		return -1
 	}
+	return n
+}
 
-	ec <- 1
+// typeFor returns the document type of a record
+func (pump *Datapump) typeFor(data PumpData) string {
+	if data.Type != "" {
+		return data.Type
+	}
+	return pump.IndexType
 }
 
-func printBulkStats(p *elastic.BulkProcessor) {
-	stats := p.Stats()
+// bulkRequest builds the bulk request matching the operation of data
+func (pump *Datapump) bulkRequest(index string, data PumpData) elastic.BulkableRequest {
+	switch data.Op {
+	case OpUpdate, OpUpsert, OpScript:
+		req := elastic.NewBulkUpdateRequest().Index(index).Type(pump.typeFor(data)).Id(data.UID).
+			Routing(data.Routing).Parent(data.Parent).Version(data.Version).VersionType(data.VersionType)
+		switch data.Op {
+		case OpScript:
+			req.Script(data.Script)
+			if data.JSON != "" {
+				req.Upsert(json.RawMessage(data.JSON))
+			}
+		case OpUpsert:
+			req.Doc(json.RawMessage(data.JSON)).DocAsUpsert(true)
+		default:
+			req.Doc(json.RawMessage(data.JSON))
+		}
+		return req
+
+	case OpDelete:
+		return elastic.NewBulkDeleteRequest().Index(index).Type(pump.typeFor(data)).Id(data.UID).
+			Routing(data.Routing).Parent(data.Parent).Version(data.Version).VersionType(data.VersionType)
+
+	default:
+		req := elastic.NewBulkIndexRequest().Index(index).Type(pump.typeFor(data)).Id(data.UID).
+			Routing(data.Routing).Parent(data.Parent).Version(data.Version).VersionType(data.VersionType).Doc(data.JSON)
+		if data.Op == OpCreate {
+			req.OpType("create")
+		}
+		return req
+	}
+}
 
+func printBulkStats(stats elastic.BulkProcessorStats) {
 	log.Infof("Number of times flush has been invoked: %d\n", stats.Flushed)
 	log.Infof("Number of times workers committed reqs: %d\n", stats.Committed)
 	log.Infof("Number of requests indexed            : %d\n", stats.Indexed)
@@ -145,22 +442,54 @@ func printBulkStats(p *elastic.BulkProcessor) {
 	}
 }
 
-func (pump *Datapump) setRefreshInterval(index, interval string) {
-
-	body := `{"index":{"refresh_interval":"` + interval + `"}}`
+// resetRefreshInterval restores the refresh interval after a run. It doesn't
+// take the run context, as it must also happen when that has been cancelled.
+func (pump *Datapump) resetRefreshInterval(index, interval string) error {
+	if interval == "" {
+		log.Infof("Resetting refresh interval of %s to the default", index)
+	} else {
+		log.Infof("Resetting refresh interval of %s to %s", index, interval)
+	}
+	return pump.setRefreshInterval(context.Background(), index, interval)
+}
 
-	// Put settings
-	putres, err := pump.Connection.Client.IndexPutSettings().Index(index).BodyString(body).Do(context.TODO())
+// refreshInterval returns the refresh interval set on index, empty if it uses the default
+func (pump *Datapump) refreshInterval(ctx context.Context, index string) (string, error) {
+	res, err := pump.Connection.Client.IndexGetSettings(index).Do(ctx)
 	if err != nil {
-		log.Fatalf("expected put settings to succeed; got: %v", err)
+		return "", errors.Wrapf(err, "Unable to get refresh_interval of index %q", index)
 	}
-	if putres == nil {
-		log.Fatalf("expected put settings response; got: %v", putres)
+
+	for _, idx := range res {
+		if idx == nil {
+			continue
+		}
+		settings, _ := idx.Settings["index"].(map[string]interface{})
+		if interval, ok := settings["refresh_interval"].(string); ok {
+			return interval, nil
+		}
 	}
-	if !putres.Acknowledged {
-		log.Fatalf("expected put settings ack; got: %v", putres.Acknowledged)
+	return "", nil
+}
+
+// setRefreshInterval sets the refresh interval of index, an empty interval
+// resets it to the default
+func (pump *Datapump) setRefreshInterval(ctx context.Context, index, interval string) error {
+	var value interface{}
+	if interval != "" {
+		value = interval
 	}
+	body := map[string]interface{}{"index": map[string]interface{}{"refresh_interval": value}}
 
-	log.Debug("Updated index with new refresh refresh_interval")
+	// Put settings
+	putres, err := pump.Connection.Client.IndexPutSettings().Index(index).BodyJson(body).Do(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to set refresh_interval of index %q", index)
+	}
+	if putres == nil || !putres.Acknowledged {
+		return errors.Wrapf(ErrNotAcknowledged, "Unable to set refresh_interval of index %q", index)
+	}
 
+	log.Debug("Updated index with new refresh_interval")
+	return nil
 }
@@ -0,0 +1,704 @@
+package esu
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+// fakeES is a minimal stand-in for the Elasticsearch endpoints used by Datapump
+type fakeES struct {
+	mu       sync.Mutex
+	docs     map[string]string
+	indices  map[string]string
+	settings []string
+	refresh  map[string]string
+	types    map[string]string
+	bulks    int
+	scrolls  map[string]*fakeScroll
+
+	// status, if set, decides the bulk item status for a document id
+	status func(id string) int
+}
+
+func newFakeES(t *testing.T) (*fakeES, *EsConnection) {
+	es := &fakeES{docs: map[string]string{}, indices: map[string]string{}, refresh: map[string]string{}, types: map[string]string{}, scrolls: map[string]*fakeScroll{}}
+	srv := httptest.NewServer(es)
+	t.Cleanup(srv.Close)
+
+	cn, err := NewByUrl(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return es, cn
+}
+
+func (es *fakeES) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method == "HEAD":
+		w.WriteHeader(http.StatusOK)
+	case strings.HasSuffix(r.URL.Path, "/_settings") && r.Method == "GET":
+		index := strings.Split(r.URL.Path, "/")[1]
+		settings := map[string]interface{}{"number_of_shards": "1"}
+		if interval, ok := es.refresh[index]; ok {
+			settings["refresh_interval"] = interval
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			index: map[string]interface{}{"settings": map[string]interface{}{"index": settings}},
+		})
+	case strings.HasSuffix(r.URL.Path, "/_settings"):
+		index := strings.Split(r.URL.Path, "/")[1]
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		interval, ok := body["index"].(map[string]interface{})["refresh_interval"].(string)
+		if ok {
+			es.refresh[index] = interval
+		} else {
+			delete(es.refresh, index)
+			interval = "default"
+		}
+		es.settings = append(es.settings, interval)
+		w.Write([]byte(`{"acknowledged":true}`))
+	case r.URL.Path == "/_bulk":
+		es.bulk(w, r)
+	case strings.HasSuffix(r.URL.Path, "/_refresh"):
+		w.Write([]byte(`{"_shards":{"total":1,"successful":1,"failed":0}}`))
+	case strings.HasSuffix(r.URL.Path, "/_count"):
+		index := strings.Split(r.URL.Path, "/")[1]
+		count := 0
+		for _, idx := range es.indices {
+			if idx == index {
+				count++
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"count": count})
+	case r.URL.Path == "/_search/scroll" && r.Method == "DELETE":
+		w.Write([]byte(`{"succeeded":true}`))
+	case r.URL.Path == "/_search/scroll":
+		var body struct {
+			ScrollID string `json:"scroll_id"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		es.page(w, body.ScrollID)
+	case strings.HasSuffix(r.URL.Path, "/_search"):
+		es.search(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (es *fakeES) bulk(w http.ResponseWriter, r *http.Request) {
+	var items []map[string]interface{}
+	failed := false
+	es.bulks++
+
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var action map[string]map[string]interface{}
+		json.Unmarshal(scanner.Bytes(), &action)
+		for op, meta := range action {
+			id, _ := meta["_id"].(string)
+			var src string
+			if op != "delete" && scanner.Scan() {
+				src = scanner.Text()
+			}
+
+			status := 201
+			if es.status != nil {
+				status = es.status(id)
+			}
+			item := map[string]interface{}{"_index": meta["_index"], "_type": meta["_type"], "_id": id, "status": status}
+			if status > 299 {
+				failed = true
+				item["error"] = map[string]interface{}{"type": "mapper_parsing_exception", "reason": "failed to parse"}
+			} else if op == "delete" {
+				delete(es.docs, id)
+			} else {
+				es.docs[id] = src
+				es.indices[id], _ = meta["_index"].(string)
+				es.types[id], _ = meta["_type"].(string)
+			}
+			items = append(items, map[string]interface{}{op: item})
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"took": 1, "errors": failed, "items": items})
+}
+
+// fakeScroll is an open scroll, the ids of the documents left to return
+type fakeScroll struct {
+	ids      []string
+	size     int
+	includes []string
+}
+
+// search opens a scroll over the documents, honouring size, slice and source includes
+func (es *fakeES) search(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Slice *struct {
+			ID  int `json:"id"`
+			Max int `json:"max"`
+		} `json:"slice"`
+		Source struct {
+			Includes []string `json:"includes"`
+		} `json:"_source"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	var ids []string
+	for id := range es.docs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	if body.Slice != nil {
+		var slice []string
+		for i, id := range ids {
+			if i%body.Slice.Max == body.Slice.ID {
+				slice = append(slice, id)
+			}
+		}
+		ids = slice
+	}
+
+	scrollID := strconv.Itoa(len(es.scrolls))
+	size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+	es.scrolls[scrollID] = &fakeScroll{ids: ids, size: size, includes: body.Source.Includes}
+	es.page(w, scrollID)
+}
+
+// page returns the next page of an open scroll
+func (es *fakeES) page(w http.ResponseWriter, scrollID string) {
+	scroll := es.scrolls[scrollID]
+	ids := scroll.ids
+	if len(ids) > scroll.size {
+		ids = ids[:scroll.size]
+	}
+	scroll.ids = scroll.ids[len(ids):]
+
+	hits := []map[string]interface{}{}
+	for _, id := range ids {
+		var source map[string]interface{}
+		json.Unmarshal([]byte(es.docs[id]), &source)
+		if len(scroll.includes) > 0 {
+			filtered := map[string]interface{}{}
+			for _, field := range scroll.includes {
+				if v, ok := source[field]; ok {
+					filtered[field] = v
+				}
+			}
+			source = filtered
+		}
+		typ := es.types[id]
+		if typ == "" {
+			typ = "doc"
+		}
+		hits = append(hits, map[string]interface{}{"_index": es.indices[id], "_type": typ, "_id": id, "_source": source})
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"_scroll_id": scrollID,
+		"hits":       map[string]interface{}{"total": len(es.docs), "hits": hits},
+	})
+}
+
+func TestDatapump_Run(t *testing.T) {
+	es, cn := newFakeES(t)
+	pump := NewDatapump(cn, "test", "doc", 10, 0, 2)
+
+	lc := make(chan PumpData)
+	go func() {
+		for _, id := range []string{"1", "2", "3"} {
+			lc <- PumpData{UID: id, JSON: `{"id":"` + id + `"}`}
+		}
+		lc <- PumpData{IsEOF: true}
+	}()
+
+	if err := pump.Run(context.Background(), lc); err != nil {
+		t.Fatal(err)
+	}
+	if len(es.docs) != 3 {
+		t.Errorf("expected 3 documents, got %d", len(es.docs))
+	}
+	if strings.Join(es.settings, ",") != "-1,default" {
+		t.Errorf("expected refresh interval to be disabled and restored, got %v", es.settings)
+	}
+}
+
+func TestDatapump_RunRestoresRefreshInterval(t *testing.T) {
+	es, cn := newFakeES(t)
+	es.refresh["test"] = "30s"
+	pump := NewDatapump(cn, "test", "doc", 10, 0, 1)
+
+	lc := make(chan PumpData)
+	go func() {
+		lc <- PumpData{UID: "1", JSON: `{"id":"1"}`}
+		lc <- PumpData{IsEOF: true}
+	}()
+
+	if err := pump.Run(context.Background(), lc); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(es.settings, ",") != "-1,30s" {
+		t.Errorf("expected refresh interval to be restored to 30s, got %v", es.settings)
+	}
+	if es.refresh["test"] != "30s" {
+		t.Errorf("expected index to keep its 30s refresh interval, got %q", es.refresh["test"])
+	}
+}
+
+func TestDatapump_RunCancelled(t *testing.T) {
+	es, cn := newFakeES(t)
+	pump := NewDatapump(cn, "test", "doc", 10, 0, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lc := make(chan PumpData)
+	go func() {
+		lc <- PumpData{UID: "1", JSON: `{"id":"1"}`}
+		cancel()
+	}()
+
+	err := pump.Run(ctx, lc)
+	if runErr, ok := err.(*RunError); !ok || errors.Cause(runErr.Err) != context.Canceled {
+		t.Fatalf("expected a cancelled RunError, got %v", err)
+	}
+	if len(es.docs) != 1 {
+		t.Errorf("expected queued document to be flushed, got %d", len(es.docs))
+	}
+	if strings.Join(es.settings, ",") != "-1,default" {
+		t.Errorf("expected refresh interval to be restored, got %v", es.settings)
+	}
+}
+
+func TestDatapump_RunFailures(t *testing.T) {
+	es, cn := newFakeES(t)
+	es.status = func(id string) int {
+		if id == "2" {
+			return http.StatusBadRequest
+		}
+		return http.StatusCreated
+	}
+
+	var buf bytes.Buffer
+	pump := NewDatapump(cn, "test", "doc", 10, 0, 1)
+	pump.Failures = NewDeadLetterWriter(&buf)
+
+	lc := make(chan PumpData)
+	go func() {
+		for _, id := range []string{"1", "2", "3"} {
+			lc <- PumpData{UID: id, JSON: `{"id":"` + id + `"}`, Version: 7, VersionType: "external"}
+		}
+		lc <- PumpData{IsEOF: true}
+	}()
+
+	err := pump.Run(context.Background(), lc)
+	if runErr, ok := err.(*RunError); !ok || runErr.Failed != 1 {
+		t.Fatalf("expected a RunError with 1 failed document, got %v", err)
+	}
+
+	var doc FailedDocument
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.UID != "2" || doc.Status != http.StatusBadRequest || doc.Error != "mapper_parsing_exception" {
+		t.Errorf("unexpected dead letter %+v", doc)
+	}
+
+	out := make(chan PumpData, 1)
+	if err := PumpDeadLetters(&buf, out); err != nil {
+		t.Fatal(err)
+	}
+	if data := <-out; data.UID != "2" || data.JSON != `{"id":"2"}` || data.Version != 7 || data.VersionType != "external" {
+		t.Errorf("unexpected re-pumped record %+v", data)
+	}
+}
+
+func TestDatapump_RunRetry(t *testing.T) {
+	es, cn := newFakeES(t)
+	attempts := map[string]int{}
+	es.status = func(id string) int {
+		attempts[id]++
+		switch {
+		case id == "1" && attempts[id] < 3:
+			return http.StatusTooManyRequests
+		case id == "2":
+			return http.StatusBadRequest
+		}
+		return http.StatusCreated
+	}
+
+	var buf bytes.Buffer
+	pump := NewDatapump(cn, "test", "doc", 10, 0, 1)
+	pump.Failures = NewDeadLetterWriter(&buf)
+	pump.Retry = NewRetryPolicy(3, time.Millisecond, 10*time.Millisecond)
+
+	lc := make(chan PumpData)
+	go func() {
+		for _, id := range []string{"1", "2", "3"} {
+			lc <- PumpData{UID: id, JSON: `{"id":"` + id + `"}`}
+		}
+		lc <- PumpData{IsEOF: true}
+	}()
+
+	err := pump.Run(context.Background(), lc)
+	if runErr, ok := err.(*RunError); !ok || runErr.Failed != 1 {
+		t.Fatalf("expected a RunError with 1 failed document, got %v", err)
+	}
+	if attempts["1"] != 3 || attempts["2"] != 1 {
+		t.Errorf("expected 3 attempts for the retryable and 1 for the rejected document, got %v", attempts)
+	}
+	if _, ok := es.docs["1"]; !ok {
+		t.Errorf("expected retried document to be indexed")
+	}
+	if strings.Count(buf.String(), "\n") != 1 {
+		t.Errorf("expected only the rejected document in the dead letters, got %q", buf.String())
+	}
+}
+
+func TestDatapump_RunRetryCancelled(t *testing.T) {
+	es, cn := newFakeES(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	es.status = func(id string) int {
+		cancel()
+		return http.StatusTooManyRequests
+	}
+
+	pump := NewDatapump(cn, "test", "doc", 10, 0, 1)
+	pump.Retry = NewRetryPolicy(3, time.Hour, time.Hour)
+
+	lc := make(chan PumpData, 2)
+	lc <- PumpData{UID: "1", JSON: `{"id":"1"}`}
+	lc <- PumpData{IsEOF: true}
+
+	done := make(chan error, 1)
+	go func() { done <- pump.Run(ctx, lc) }()
+
+	select {
+	case err := <-done:
+		if runErr, ok := err.(*RunError); !ok || runErr.Failed != 1 {
+			t.Fatalf("expected a RunError with 1 failed document, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected cancelling the run to stop the retry backoff")
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	rp := &RetryPolicy{InitialInterval: 100 * time.Millisecond, MaxInterval: time.Second}
+	for retry, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		if got := rp.Backoff(retry); got != want*time.Millisecond {
+			t.Errorf("retry %d: expected %v, got %v", retry, want*time.Millisecond, got)
+		}
+	}
+	if !rp.Retryable(http.StatusTooManyRequests) || rp.Retryable(http.StatusBadRequest) {
+		t.Errorf("expected only default statuses to be retryable")
+	}
+}
+
+func TestDatapump_bulkRequest(t *testing.T) {
+	pump := NewDatapump(nil, "test", "doc", 10, 0, 1)
+	script := elastic.NewScriptInline("ctx._source.n += 1").Lang("painless")
+
+	tests := []struct {
+		data   PumpData
+		action string
+		source string
+	}{
+		{PumpData{UID: "1", JSON: `{"a":1}`},
+			`{"index":{"_id":"1","_index":"test","_type":"doc"}}`, `{"a":1}`},
+		{PumpData{UID: "1", JSON: `{"a":1}`, Op: OpCreate, Routing: "r"},
+			`{"create":{"_id":"1","_index":"test","_type":"doc","_routing":"r"}}`, `{"a":1}`},
+		{PumpData{UID: "1", JSON: `{"a":1}`, Op: OpUpdate, Version: 3, VersionType: "external"},
+			`{"update":{"_id":"1","_index":"test","_type":"doc","_version":3,"_version_type":"external"}}`, `{"doc":{"a":1}}`},
+		{PumpData{UID: "1", JSON: `{"a":1}`, Op: OpUpsert},
+			`{"update":{"_id":"1","_index":"test","_type":"doc"}}`, `{"doc":{"a":1},"doc_as_upsert":true}`},
+		{PumpData{UID: "1", JSON: `{"n":0}`, Op: OpScript, Script: script},
+			`{"update":{"_id":"1","_index":"test","_type":"doc"}}`, `{"upsert":{"n":0},"script":{"inline":"ctx._source.n += 1","lang":"painless"}}`},
+		{PumpData{UID: "1", Op: OpDelete, Parent: "p"},
+			`{"delete":{"_id":"1","_index":"test","_parent":"p","_type":"doc"}}`, ""},
+	}
+
+	for _, test := range tests {
+		lines, err := pump.bulkRequest("test", test.data).Source()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lines[0] != test.action {
+			t.Errorf("%s: expected action %s, got %s", test.data.Op, test.action, lines[0])
+		}
+		if test.source != "" && lines[1] != test.source {
+			t.Errorf("%s: expected source %s, got %s", test.data.Op, test.source, lines[1])
+		}
+
+		back := failedDocument(pump.bulkRequest("test", test.data)).PumpData()
+		if back.Op != test.data.Op || back.JSON != test.data.JSON || back.Routing != test.data.Routing ||
+			back.Version != test.data.Version || back.VersionType != test.data.VersionType {
+			t.Errorf("%s: dead letter did not round trip, got %+v", test.data.Op, back)
+		}
+	}
+}
+
+func TestDatapump_RunBulkSize(t *testing.T) {
+	es, cn := newFakeES(t)
+	pump := NewDatapump(cn, "test", "doc", 100, 50, 1)
+
+	lc := make(chan PumpData)
+	go func() {
+		for _, id := range []string{"1", "2", "3"} {
+			lc <- PumpData{UID: id, JSON: `{"id":"` + id + `"}`}
+		}
+		lc <- PumpData{IsEOF: true}
+	}()
+
+	if err := pump.Run(context.Background(), lc); err != nil {
+		t.Fatal(err)
+	}
+	if es.bulks != 3 {
+		t.Errorf("expected a bulk request per document, got %d", es.bulks)
+	}
+}
+
+func TestDatapump_RunFlushInterval(t *testing.T) {
+	es, cn := newFakeES(t)
+	pump := NewDatapump(cn, "test", "doc", 100, 0, 1)
+	pump.FlushInterval = 10 * time.Millisecond
+
+	lc := make(chan PumpData)
+	done := make(chan error)
+	go func() { done <- pump.Run(context.Background(), lc) }()
+
+	lc <- PumpData{UID: "1", JSON: `{"id":"1"}`}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		es.mu.Lock()
+		n := len(es.docs)
+		es.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the document to be flushed before the end of the run")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	lc <- PumpData{IsEOF: true}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDatapump_RunResolveIndex(t *testing.T) {
+	es, cn := newFakeES(t)
+	pump := NewDatapump(cn, "", "doc", 10, 0, 1)
+	pump.ResolveIndex = TimestampIndex("events-", "meta.ts", "2006.01.02")
+
+	lc := make(chan PumpData)
+	go func() {
+		lc <- PumpData{UID: "1", JSON: `{"meta":{"ts":"2026-10-17T10:00:00Z"}}`}
+		lc <- PumpData{UID: "2", JSON: `{"meta":{"ts":1792317600000}}`}
+		lc <- PumpData{UID: "3", JSON: `{"meta":{"ts":"2026-10-17T23:00:00+02:00"}}`}
+		lc <- PumpData{UID: "4", JSON: `{}`, Index: "other"}
+		lc <- PumpData{IsEOF: true}
+	}()
+
+	if err := pump.Run(context.Background(), lc); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"1": "events-2026.10.17", "2": "events-2026.10.18", "3": "events-2026.10.17", "4": "other"}
+	for id, index := range want {
+		if es.indices[id] != index {
+			t.Errorf("document %s: expected index %s, got %s", id, index, es.indices[id])
+		}
+	}
+	if len(es.settings) != 6 {
+		t.Errorf("expected refresh interval of 3 indices to be disabled and restored, got %v", es.settings)
+	}
+}
+
+func TestDatapump_RunRejectedRecords(t *testing.T) {
+	es, cn := newFakeES(t)
+	resolve := TimestampIndex("events-", "ts", "2006.01.02")
+
+	var buf bytes.Buffer
+	pump := NewDatapump(cn, "", "doc", 10, 0, 1)
+	pump.Failures = NewDeadLetterWriter(&buf)
+	pump.ResolveIndex = func(data PumpData) (string, error) {
+		if data.UID == "skip" {
+			return "", ErrSkipDocument
+		}
+		return resolve(data)
+	}
+
+	lc := make(chan PumpData)
+	go func() {
+		lc <- PumpData{UID: "1", JSON: `{"ts":"2026-10-17T10:00:00Z"}`}
+		lc <- PumpData{UID: "2", JSON: `{}`}
+		lc <- PumpData{UID: "3", Rec: map[string]interface{}{"ch": make(chan int)}}
+		lc <- PumpData{UID: "skip", JSON: `{}`}
+		lc <- PumpData{UID: "4", JSON: `{"ts":"2026-10-17T11:00:00Z"}`}
+		lc <- PumpData{IsEOF: true}
+	}()
+
+	err := pump.Run(context.Background(), lc)
+	if runErr, ok := err.(*RunError); !ok || runErr.Err != nil || runErr.Failed != 2 || runErr.Rows != 5 {
+		t.Fatalf("expected a finished RunError with 2 of 5 rows failed, got %v", err)
+	}
+	if len(es.docs) != 2 {
+		t.Errorf("expected the records after the rejected ones to be indexed, got %v", es.docs)
+	}
+
+	var ids []string
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var doc FailedDocument
+		if err := json.Unmarshal(scanner.Bytes(), &doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc.Error != "record_rejected" || doc.Reason == "" {
+			t.Errorf("unexpected dead letter %+v", doc)
+		}
+		ids = append(ids, doc.UID)
+	}
+	if strings.Join(ids, ",") != "2,3" {
+		t.Errorf("expected records 2 and 3 in the dead letters, got %v", ids)
+	}
+}
+
+func TestDatapump_RunRec(t *testing.T) {
+	type event struct {
+		ID   int    `json:"-" esu:"id"`
+		Name string `json:"name"`
+	}
+
+	es, cn := newFakeES(t)
+	pump := NewDatapump(cn, "test", "doc", 10, 0, 1)
+
+	lc := make(chan PumpData)
+	go func() {
+		lc <- PumpData{Rec: event{ID: 1, Name: "first"}}
+		lc <- PumpData{Rec: &event{ID: 2, Name: "second"}}
+		lc <- PumpData{Rec: map[string]string{"name": "third"}, UID: "3"}
+		lc <- PumpData{IsEOF: true}
+	}()
+
+	if err := pump.Run(context.Background(), lc); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"1": `{"name":"first"}`, "2": `{"name":"second"}`, "3": `{"name":"third"}`}
+	for id, doc := range want {
+		if es.docs[id] != doc {
+			t.Errorf("document %s: expected %s, got %s", id, doc, es.docs[id])
+		}
+	}
+}
+
+func TestTagID(t *testing.T) {
+	id := 7
+	name := "seven"
+	tests := []struct {
+		rec      interface{}
+		expected string
+	}{
+		{struct {
+			ID int `esu:"id"`
+		}{ID: 1}, "1"},
+		{&struct {
+			ID *int `esu:"id"`
+		}{ID: &id}, "7"},
+		{struct {
+			ID *string `esu:"id"`
+		}{ID: &name}, "seven"},
+		{struct {
+			ID *string `esu:"id"`
+		}{}, ""},
+		{struct {
+			ID interface{} `esu:"id"`
+		}{ID: &id}, "7"},
+		{map[string]string{"id": "1"}, ""},
+	}
+	for _, test := range tests {
+		got, err := TagID(test.rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.expected {
+			t.Errorf("%#v: expected id %q, got %q", test.rec, test.expected, got)
+		}
+	}
+
+	unexported := struct {
+		id   int `esu:"id"`
+		Name string
+	}{id: 1}
+	if _, err := TagID(unexported); err == nil {
+		t.Error("expected an error for an unexported id field")
+	}
+}
+
+func TestDatapump_Stats(t *testing.T) {
+	es, cn := newFakeES(t)
+	es.status = func(id string) int {
+		if id == "2" {
+			return http.StatusBadRequest
+		}
+		return http.StatusCreated
+	}
+
+	pump := NewDatapump(cn, "test", "doc", 10, 0, 1)
+	pump.ProgressInterval = time.Millisecond
+
+	var mu sync.Mutex
+	var progress []PumpStats
+	pump.OnProgress = func(s PumpStats) {
+		mu.Lock()
+		progress = append(progress, s)
+		mu.Unlock()
+	}
+
+	lc := make(chan PumpData)
+	go func() {
+		for _, id := range []string{"1", "2", "3"} {
+			lc <- PumpData{UID: id, JSON: `{"id":"` + id + `"}`}
+		}
+		time.Sleep(10 * time.Millisecond)
+		lc <- PumpData{IsEOF: true}
+	}()
+
+	pump.Run(context.Background(), lc)
+
+	stats := pump.Stats()
+	if stats.Rows != 3 || stats.Bytes != 30 || stats.Succeeded != 2 || stats.Failed != 1 || stats.InFlight != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if stats.Bulk.Committed != 1 || len(stats.Bulk.Workers) != 1 {
+		t.Errorf("unexpected bulk stats %+v", stats.Bulk)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(progress) < 2 {
+		t.Fatalf("expected periodic and final progress, got %d reports", len(progress))
+	}
+	if last := progress[len(progress)-1]; last.Rows != 3 || last.Failed != 1 {
+		t.Errorf("unexpected final progress %+v", last)
+	}
+}
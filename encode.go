@@ -0,0 +1,85 @@
+package esu
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// Encoder serializes a PumpData.Rec value to a JSON document
+type Encoder func(v interface{}) ([]byte, error)
+
+// IDExtractor derives the document id of a PumpData.Rec value.
+// An empty id lets Elasticsearch generate one.
+type IDExtractor func(v interface{}) (string, error)
+
+// TagID is the default IDExtractor. It returns the value of the struct field
+// tagged `esu:"id"`, or an empty id if v isn't a struct, has no such field or
+// the field is a nil pointer. The field must be exported.
+func TagID(v interface{}) (string, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return "", nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return "", nil
+	}
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		if rt.Field(i).Tag.Get("esu") != "id" {
+			continue
+		}
+
+		field := rv.Field(i)
+		if !field.CanInterface() {
+			return "", errors.Errorf("Id field %s of %s is unexported", rt.Field(i).Name, rt)
+		}
+		for field.Kind() == reflect.Ptr || field.Kind() == reflect.Interface {
+			if field.IsNil() {
+				return "", nil
+			}
+			field = field.Elem()
+		}
+		return fmt.Sprint(field.Interface()), nil
+	}
+	return "", nil
+}
+
+// encode fills in the JSON and UID of a record from its Rec value
+func (pump *Datapump) encode(data PumpData) (PumpData, error) {
+	if data.Rec == nil {
+		return data, nil
+	}
+
+	if data.UID == "" {
+		extract := pump.ExtractID
+		if extract == nil {
+			extract = TagID
+		}
+		id, err := extract(data.Rec)
+		if err != nil {
+			return data, errors.Wrap(err, "Unable to extract record id")
+		}
+		data.UID = id
+	}
+
+	if data.JSON == "" && data.Op != OpDelete {
+		encode := pump.Encoder
+		if encode == nil {
+			encode = json.Marshal
+		}
+		body, err := encode(data.Rec)
+		if err != nil {
+			return data, errors.Wrapf(err, "Unable to encode record %q", data.UID)
+		}
+		data.JSON = string(body)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,72 @@
+package esu
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNoNodes is returned when Elasticsearch reports an empty node list
+	ErrNoNodes = errors.New("Elasticsearch node list unexpectedly empty")
+
+	// ErrNotAcknowledged is returned when Elasticsearch did not acknowledge a change
+	ErrNotAcknowledged = errors.New("Elasticsearch did not acknowledge the request")
+
+	// ErrNoInput is returned by OpenInput when stdin is a terminal or empty
+	ErrNoInput = errors.New("No input given on stdin")
+
+	// ErrSkipDocument is returned by a Transform or an IndexResolver to leave a document out
+	ErrSkipDocument = errors.New("Skip document")
+)
+
+// InvalidVersionError is returned when Elasticsearch reports a version string that can't be parsed
+type InvalidVersionError struct {
+	Version string
+}
+
+func (e *InvalidVersionError) Error() string {
+	return fmt.Sprintf("ES returned invalid version: %q", e.Version)
+}
+
+// UnsupportedVersionError is returned when an operation needs a later version of Elasticsearch
+type UnsupportedVersionError struct {
+	Operation string
+	Version   ESVersion // the version of the cluster
+	Required  ESVersion // the first version with the operation
+}
+
+func (e *UnsupportedVersionError) Error() string {
+	return fmt.Sprintf("%s requires Elasticsearch %s or later, the cluster runs %s", e.Operation, e.Required, e.Version)
+}
+
+// RunError summarizes a Datapump run that was interrupted or had failed documents
+type RunError struct {
+	Rows   int   // number of records read from the channel
+	Failed int64 // number of documents reported as failed
+	Err    error // reason the run stopped early, if any
+}
+
+func (e *RunError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("Datapump stopped after %d rows (%d failed): %v", e.Rows, e.Failed, e.Err)
+	}
+	return fmt.Sprintf("Datapump finished %d rows with %d failed", e.Rows, e.Failed)
+}
+
+// Unwrap returns the reason the run stopped early
+func (e *RunError) Unwrap() error {
+	return e.Err
+}
+
+// CountMismatchError is returned by Reindex when the target index doesn't
+// hold the number of documents expected from the source
+type CountMismatchError struct {
+	Source  int64 // documents in the source matching the query
+	Skipped int64 // documents left out by the transform
+	Target  int64 // documents in the target index
+}
+
+func (e *CountMismatchError) Error() string {
+	return fmt.Sprintf("Expected %d documents in target (%d in source, %d skipped), found %d", e.Source-e.Skipped, e.Source, e.Skipped, e.Target)
+}
@@ -3,34 +3,47 @@ package esu
 import (
 	"net/url"
 
+	"github.com/pkg/errors"
 	elastic "gopkg.in/olivere/elastic.v5"
 )
 
 // VERSION is the lib version
 const VERSION = "0.3.1"
 
-// EsConnection is a container for elasticsearch connection information
+// EsConnection is a container for elasticsearch connection information.
+// Scheme, Host, Port and URL describe the first node the connection was created with.
 type EsConnection struct {
 	Scheme string
 	Host   string
 	Port   string
 	URL    *url.URL
+	URLs   []*url.URL
 	Client *elastic.Client
 }
 
-// New Creates a  ES connection object
-func New(scheme, host, port string) *EsConnection {
-	connection := EsConnection{Scheme: scheme, Host: host, Port: port}
-	connection.URL = getConnectionURL(scheme, host, port)
-	connection.Client = connectToES(connection.URL.String())
+// ConnectionSettings controls how the client finds and checks the nodes of the cluster
+type ConnectionSettings struct {
+	// Sniff discovers the other nodes of the cluster from the seed urls
+	Sniff bool
+	// Healthcheck periodically pings the nodes and stops using dead ones
+	Healthcheck bool
+}
 
-	return &connection
+// New Creates a  ES connection object
+func New(scheme, host, port string) (*EsConnection, error) {
+	return Connect(WithURLs(getConnectionURL(scheme, host, port).String()))
 }
 
 // NewByUrl Creates a  ES connection object based on elastic url
-func NewByUrl(url string) *EsConnection {
-	connection := EsConnection{}
-	connection.Client = connectToES(url)
+func NewByUrl(uri string) (*EsConnection, error) {
+	return Connect(WithURLs(uri))
+}
 
-	return &connection
+// NewByUrls Creates a ES connection object with several seed node urls. Requests
+// are spread over the nodes, and with settings.Healthcheck dead nodes are skipped.
+func NewByUrls(uris []string, settings ConnectionSettings) (*EsConnection, error) {
+	if len(uris) == 0 {
+		return nil, errors.New("No Elasticsearch url given")
+	}
+	return Connect(WithURLs(uris...), WithSettings(settings))
 }
@@ -1,7 +1,10 @@
 package esu
 
 import (
+	"context"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -23,10 +26,47 @@ func TestUtils_getConnectionURL(t *testing.T) {
 	host := EnvGetWithDefault("ES_HOST", "localhost")
 	port := EnvGetWithDefault("ES_PORT", "9200")
 
-	connection := New(protocol, host, port)
+	connection, err := New(protocol, host, port)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	connection.Ping()
+	ctx := context.Background()
 
-	connection.getClusterHealth()
+	ping, err := connection.Ping(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	PingTable(connection.URL.String(), ping).Print()
 
+	health, err := connection.ClusterHealth(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ClusterHealthTable(health).Print()
+
+}
+
+func TestNewByUrls(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"node-1","cluster_name":"test","version":{"number":"5.5.2"}}`))
+	}))
+	defer srv.Close()
+
+	connection, err := NewByUrls([]string{srv.URL, "http://127.0.0.1:1"}, ConnectionSettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if connection.URL.String() != srv.URL || len(connection.URLs) != 2 || connection.Scheme != "http" {
+		t.Errorf("unexpected connection %+v", connection)
+	}
+
+	ping, err := connection.Ping(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ping.ClusterName != "test" {
+		t.Errorf("expected cluster test, got %q", ping.ClusterName)
+	}
 }
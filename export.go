@@ -0,0 +1,191 @@
+package esu
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+// Export defaults
+const (
+	DefaultExportPageSize  = 1000
+	DefaultScrollKeepAlive = "5m"
+)
+
+// ExportHit is a line of export output, the document source with its metadata.
+// NDJSONReader reads it back when Hits is set.
+type ExportHit struct {
+	Index   string          `json:"_index"`
+	Type    string          `json:"_type"`
+	ID      string          `json:"_id"`
+	Routing string          `json:"_routing,omitempty"`
+	Parent  string          `json:"_parent,omitempty"`
+	Source  json.RawMessage `json:"_source"`
+}
+
+// Exporter streams the documents of an index using the scroll API
+type Exporter struct {
+	Connection *EsConnection
+	Index      string
+
+	// Query selects the exported documents, all documents if nil
+	Query elastic.Query
+
+	// Fields limits the exported source to these fields, which may contain
+	// wildcards. The whole source is exported if empty.
+	Fields []string
+
+	// Slices is the number of scrolls run in parallel, each over a slice of the index
+	Slices int
+
+	// PageSize is the number of documents fetched per request
+	PageSize int
+
+	// KeepAlive is how long Elasticsearch keeps a scroll open between requests
+	KeepAlive string
+
+	// Gzip compresses the output of Export
+	Gzip bool
+
+	exported int64
+}
+
+// NewExporter creates an exporter of the documents in index
+func NewExporter(cn *EsConnection, index string) *Exporter {
+	return &Exporter{
+		Connection: cn,
+		Index:      index,
+		Slices:     1,
+		PageSize:   DefaultExportPageSize,
+		KeepAlive:  DefaultScrollKeepAlive,
+	}
+}
+
+// Exported returns the number of documents exported so far
+func (ex *Exporter) Exported() int64 {
+	return atomic.LoadInt64(&ex.exported)
+}
+
+// Scroll calls fn for every document. With several slices fn is called from
+// several goroutines at once. The first error stops all slices.
+func (ex *Exporter) Scroll(ctx context.Context, fn func(hit *elastic.SearchHit) error) error {
+	slices := ex.Slices
+	if slices <= 1 {
+		return ex.scroll(ctx, nil, fn)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for i := 0; i < slices; i++ {
+		wg.Add(1)
+		go func(slice elastic.Query) {
+			defer wg.Done()
+			if err := ex.scroll(ctx, slice, fn); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(elastic.NewSliceQuery().Id(i).Max(slices))
+	}
+	wg.Wait()
+	return firstErr
+}
+
+func (ex *Exporter) scroll(ctx context.Context, slice elastic.Query, fn func(hit *elastic.SearchHit) error) error {
+	svc := ex.Connection.Client.Scroll(ex.Index).
+		Size(ex.PageSize).
+		KeepAlive(ex.KeepAlive)
+	if ex.Query != nil {
+		svc = svc.Query(ex.Query)
+	}
+	if len(ex.Fields) > 0 {
+		svc = svc.FetchSourceContext(elastic.NewFetchSourceContext(true).Include(ex.Fields...))
+	}
+	if slice != nil {
+		svc = svc.Slice(slice)
+	}
+	// The scroll is released on the server even if ctx is done
+	defer svc.Clear(context.Background())
+
+	for {
+		res, err := svc.Do(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "Unable to scroll index %s", ex.Index)
+		}
+
+		for _, hit := range res.Hits.Hits {
+			if err := fn(hit); err != nil {
+				return err
+			}
+			atomic.AddInt64(&ex.exported, 1)
+		}
+	}
+}
+
+// Pump sends a record for every document on lc until all are exported or
+// ctx is done, keeping its type, routing and parent. It does not send an EOF record.
+func (ex *Exporter) Pump(ctx context.Context, lc chan<- PumpData) error {
+	return ex.Scroll(ctx, func(hit *elastic.SearchHit) error {
+		data := PumpData{UID: hit.Id, Type: hit.Type, Routing: hit.Routing, Parent: hit.Parent}
+		if hit.Source != nil {
+			data.JSON = string(*hit.Source)
+		}
+
+		select {
+		case lc <- data:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// Export writes every document to w as a line of JSON in the ExportHit format
+func (ex *Exporter) Export(ctx context.Context, w io.Writer) error {
+	var gz *gzip.Writer
+	if ex.Gzip {
+		gz = gzip.NewWriter(w)
+		w = gz
+	}
+	bw := bufio.NewWriter(w)
+
+	var mu sync.Mutex
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+	err := ex.Scroll(ctx, func(hit *elastic.SearchHit) error {
+		line := ExportHit{Index: hit.Index, Type: hit.Type, ID: hit.Id, Routing: hit.Routing, Parent: hit.Parent}
+		if hit.Source != nil {
+			line.Source = *hit.Source
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		return errors.Wrap(enc.Encode(line), "Unable to write export")
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := bw.Flush(); err != nil {
+		return errors.Wrap(err, "Unable to write export")
+	}
+	if gz != nil {
+		return errors.Wrap(gz.Close(), "Unable to write export")
+	}
+	return nil
+}
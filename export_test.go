@@ -0,0 +1,76 @@
+package esu
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestExporter_Export(t *testing.T) {
+	es, cn := newFakeES(t)
+	for i := 0; i < 25; i++ {
+		id := fmt.Sprintf("%02d", i)
+		es.docs[id] = fmt.Sprintf(`{"id":"%s","n":%d,"name":"doc %d"}`, id, i, i)
+		es.indices[id] = "source"
+	}
+
+	for _, slices := range []int{1, 3} {
+		var buf bytes.Buffer
+		ex := NewExporter(cn, "source")
+		ex.Slices = slices
+		ex.PageSize = 4
+		ex.Gzip = true
+		if err := ex.Export(context.Background(), &buf); err != nil {
+			t.Fatal(err)
+		}
+		if ex.Exported() != 25 {
+			t.Errorf("%d slices: expected 25 exported documents, got %d", slices, ex.Exported())
+		}
+
+		// The export imports into another index with the same ids and sources
+		dst, dcn := newFakeES(t)
+		nr, err := NewNDJSONReader(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nr.Hits = true
+
+		lc := make(chan PumpData)
+		go func() {
+			defer close(lc)
+			if err := nr.Pump(context.Background(), lc); err != nil {
+				t.Error(err)
+			}
+		}()
+		if err := NewDatapump(dcn, "copy", "doc", 10, 0, 1).Run(context.Background(), lc); err != nil {
+			t.Fatal(err)
+		}
+
+		if nr.Malformed != 0 || len(dst.docs) != 25 {
+			t.Fatalf("%d slices: expected 25 imported documents, got %d with %d malformed", slices, len(dst.docs), nr.Malformed)
+		}
+		for id, src := range es.docs {
+			if dst.docs[id] != src || dst.indices[id] != "copy" {
+				t.Errorf("%d slices: expected %s in copy, got %s in %s", slices, src, dst.docs[id], dst.indices[id])
+			}
+		}
+	}
+}
+
+func TestExporter_Fields(t *testing.T) {
+	es, cn := newFakeES(t)
+	es.docs["1"] = `{"id":"1","name":"first","secret":"x"}`
+
+	ex := NewExporter(cn, "source")
+	ex.Fields = []string{"id", "name"}
+
+	lc := make(chan PumpData, 1)
+	if err := ex.Pump(context.Background(), lc); err != nil {
+		t.Fatal(err)
+	}
+	if data := <-lc; data.UID != "1" || strings.Contains(data.JSON, "secret") {
+		t.Errorf("expected filtered source of document 1, got %+v", data)
+	}
+}
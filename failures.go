@@ -0,0 +1,258 @@
+package esu
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+// FailedDocument is a document that Elasticsearch rejected during a bulk request
+type FailedDocument struct {
+	Op          string          `json:"op"`
+	Index       string          `json:"index"`
+	Type        string          `json:"type"`
+	UID         string          `json:"uid"`
+	Routing     string          `json:"routing,omitempty"`
+	Parent      string          `json:"parent,omitempty"`
+	Version     int64           `json:"version,omitempty"`
+	VersionType string          `json:"version_type,omitempty"`
+	Source      json.RawMessage `json:"source,omitempty"`
+	Status      int             `json:"status"`
+	Error       string          `json:"error"`
+	Reason      string          `json:"reason"`
+}
+
+// PumpData turns the failed document back into a record for a Datapump
+func (doc FailedDocument) PumpData() PumpData {
+	data := PumpData{UID: doc.UID, Index: doc.Index, Routing: doc.Routing, Parent: doc.Parent,
+		Version: doc.Version, VersionType: doc.VersionType}
+
+	switch doc.Op {
+	case "create":
+		data.Op = OpCreate
+	case "delete":
+		data.Op = OpDelete
+		return data
+	case "update":
+		var body struct {
+			Doc         json.RawMessage        `json:"doc"`
+			DocAsUpsert bool                   `json:"doc_as_upsert"`
+			Upsert      json.RawMessage        `json:"upsert"`
+			Script      map[string]interface{} `json:"script"`
+		}
+		json.Unmarshal(doc.Source, &body)
+		switch {
+		case body.Script != nil:
+			data.Op = OpScript
+			data.Script = scriptFromSource(body.Script)
+			data.JSON = string(body.Upsert)
+		case body.DocAsUpsert:
+			data.Op = OpUpsert
+			data.JSON = string(body.Doc)
+		default:
+			data.Op = OpUpdate
+			data.JSON = string(body.Doc)
+		}
+		return data
+	}
+
+	if json.Unmarshal(doc.Source, &data.JSON) != nil {
+		// Not a string, so the source was valid JSON and stored as is
+		data.JSON = string(doc.Source)
+	}
+	return data
+}
+
+// scriptFromSource rebuilds a script from its serialized form
+func scriptFromSource(src map[string]interface{}) *elastic.Script {
+	script := elastic.NewScript("")
+	for _, typ := range []string{"inline", "source", "id", "file"} {
+		if s, ok := src[typ].(string); ok {
+			script.Script(s)
+			if typ == "id" || typ == "file" {
+				script.Type(typ)
+			}
+		}
+	}
+	if lang, ok := src["lang"].(string); ok {
+		script.Lang(lang)
+	}
+	if params, ok := src["params"].(map[string]interface{}); ok {
+		script.Params(params)
+	}
+	return script
+}
+
+// FailureSink receives every document Elasticsearch rejected.
+// It may be called concurrently from several bulk workers.
+type FailureSink interface {
+	Failed(doc FailedDocument) error
+}
+
+// FailureSinkFunc adapts a function to a FailureSink
+type FailureSinkFunc func(doc FailedDocument) error
+
+// Failed calls f(doc)
+func (f FailureSinkFunc) Failed(doc FailedDocument) error {
+	return f(doc)
+}
+
+// DeadLetterWriter writes failed documents as JSON lines
+type DeadLetterWriter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+}
+
+// NewDeadLetterWriter creates a dead-letter sink writing to w
+func NewDeadLetterWriter(w io.Writer) *DeadLetterWriter {
+	return &DeadLetterWriter{w: w, enc: json.NewEncoder(w)}
+}
+
+// NewDeadLetterFile creates a dead-letter sink appending to the file at path
+func NewDeadLetterFile(path string) (*DeadLetterWriter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to open dead-letter file %q", path)
+	}
+	return NewDeadLetterWriter(f), nil
+}
+
+// Failed writes doc as a single JSON line
+func (dl *DeadLetterWriter) Failed(doc FailedDocument) error {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	return dl.enc.Encode(doc)
+}
+
+// Close closes the underlying writer if it is closable
+func (dl *DeadLetterWriter) Close() error {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	if c, ok := dl.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// PumpDeadLetters reads a dead-letter file written by DeadLetterWriter and
+// sends each document on lc. It does not send an EOF record.
+func PumpDeadLetters(r io.Reader, lc chan<- PumpData) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var doc FailedDocument
+		if err := json.Unmarshal(scanner.Bytes(), &doc); err != nil {
+			return errors.Wrap(err, "Invalid dead-letter record")
+		}
+		lc <- doc.PumpData()
+	}
+	return scanner.Err()
+}
+
+// bulkFailure pairs a rejected bulk request with the response item that rejected it
+type bulkFailure struct {
+	req  elastic.BulkableRequest
+	item *elastic.BulkResponseItem
+}
+
+// bulkFailures collects the requests of a bulk commit that came back with an error status
+func bulkFailures(requests []elastic.BulkableRequest, response *elastic.BulkResponse) []bulkFailure {
+	var failures []bulkFailure
+	if response == nil {
+		return failures
+	}
+	for i, req := range requests {
+		if i >= len(response.Items) {
+			break
+		}
+		item := bulkResponseItem(response.Items[i])
+		if item == nil || (item.Status >= 200 && item.Status <= 299 && item.Error == nil) {
+			continue
+		}
+		failures = append(failures, bulkFailure{req: req, item: item})
+	}
+	return failures
+}
+
+// reportFailures sends every rejected request to sink
+func reportFailures(sink FailureSink, failures []bulkFailure) {
+	for _, f := range failures {
+		doc := failedDocument(f.req)
+		doc.Status = f.item.Status
+		if f.item.Error != nil {
+			doc.Error = f.item.Error.Type
+			doc.Reason = f.item.Error.Reason
+		}
+		sendFailure(sink, doc)
+	}
+}
+
+// reportRequestError sends every request of a bulk commit that failed as a whole to sink
+func reportRequestError(sink FailureSink, requests []elastic.BulkableRequest, err error) {
+	for _, req := range requests {
+		doc := failedDocument(req)
+		doc.Error = "request_failed"
+		doc.Reason = err.Error()
+		if e, ok := errors.Cause(err).(*elastic.Error); ok {
+			doc.Status = e.Status
+		}
+		sendFailure(sink, doc)
+	}
+}
+
+func sendFailure(sink FailureSink, doc FailedDocument) {
+	if err := sink.Failed(doc); err != nil {
+		log.Errorf("Unable to record failed document %q: %s", doc.UID, err)
+	}
+}
+
+// failedDocument extracts index, id and source from a bulk request
+func failedDocument(req elastic.BulkableRequest) FailedDocument {
+	var doc FailedDocument
+
+	lines, err := req.Source()
+	if err != nil || len(lines) == 0 {
+		return doc
+	}
+
+	var action map[string]struct {
+		Index       string `json:"_index"`
+		Type        string `json:"_type"`
+		ID          string `json:"_id"`
+		Routing     string `json:"_routing"`
+		Parent      string `json:"_parent"`
+		Version     int64  `json:"_version"`
+		VersionType string `json:"_version_type"`
+	}
+	if json.Unmarshal([]byte(lines[0]), &action) == nil {
+		for op, meta := range action {
+			doc.Op = op
+			doc.Index, doc.Type, doc.UID = meta.Index, meta.Type, meta.ID
+			doc.Routing, doc.Parent = meta.Routing, meta.Parent
+			doc.Version, doc.VersionType = meta.Version, meta.VersionType
+		}
+	}
+	if len(lines) > 1 {
+		if json.Valid([]byte(lines[1])) {
+			doc.Source = json.RawMessage(lines[1])
+		} else {
+			// Keep sources ES couldn't parse as a JSON string
+			doc.Source, _ = json.Marshal(lines[1])
+		}
+	}
+	return doc
+}
+
+func bulkResponseItem(m map[string]*elastic.BulkResponseItem) *elastic.BulkResponseItem {
+	for _, item := range m {
+		return item
+	}
+	return nil
+}
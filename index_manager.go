@@ -2,9 +2,12 @@ package esu
 
 import (
 	"encoding/json"
-	"fmt"
+	"net/url"
+	"path"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/pkg/errors"
 	logger "github.com/sirupsen/logrus"
@@ -20,34 +23,152 @@ import (
 type CreateFlags struct {
 	// Temporary sets whether to make this index a temporary one. It will optimize for writing,
 	// by disabling replication, disabling refresh, and disabling translog durability.
+	// The number of replicas it is meant to have is kept in its mapping _meta
+	// until it is made permanent, which before ES 7.0 needs a type in mappings.
 	Temporary bool
+
+	// Settings are merged over the manager's index settings for this index.
+	Settings map[string]interface{}
+
+	// Shards and Replicas set the number of primary shards and replicas, if not nil.
+	// A temporary index gets its replicas when made permanent.
+	Shards   *int
+	Replicas *int
+
+	// Aliases are added to the index as it is created.
+	Aliases []string
+
+	// WaitForActiveShards is how many shard copies must be active before the
+	// create request returns, a number or "all". Defaults to the primaries.
+	WaitForActiveShards string
+
+	// WaitForStatus is the health to wait for once created, "green", "yellow"
+	// or "none" to not wait. Defaults to yellow.
+	WaitForStatus string
+
+	// Timeout is how long to wait for the index to be created and reach
+	// its health status. Defaults to 30s.
+	Timeout string
+}
+
+// PermanentFlags are flags you can pass to IndexManager's MakePermanent method.
+type PermanentFlags struct {
+	// Replicas sets the number of replicas. If nil, the index gets the number it
+	// was meant to have when created, or else the one of the matching templates.
+	Replicas *int
+
+	// WaitForStatus is the health to wait for, "green" or "yellow".
+	// Defaults to yellow.
+	WaitForStatus string
+
+	// Timeout is how long to wait for the health status. Defaults to 30s.
+	Timeout string
 }
 
 // IndexManager manages indexes.
 type IndexManager interface {
 	// Create creates a new index. If temporary is true, the index is created
 	// with less durable settings.
-	Create(indexName string, flags CreateFlags, mappings interface{}) error
+	Create(ctx context.Context, indexName string, flags CreateFlags, mappings interface{}) error
 
 	// Delete deletes an index.
-	Delete(indexName string) error
+	Delete(ctx context.Context, indexName string) error
 
-	// MakePermanent transitions a temporary index to a permanent one.
-	MakePermanent(indexName string) error
+	// MakePermanent transitions a temporary index to a permanent one, and
+	// waits for its health to turn yellow or green.
+	MakePermanent(ctx context.Context, indexName string, flags PermanentFlags) error
 
 	// GetNames returns the names of all existing indexes.
-	GetNames() ([]string, error)
+	GetNames(ctx context.Context) ([]string, error)
 
 	// IndexExists checks if the index exists.
-	IndexExists(indexName string) (bool, error)
+	IndexExists(ctx context.Context, indexName string) (bool, error)
+
+	// GetAliases returns the aliases of the indexes matching indexName, or of
+	// all indexes if indexName is empty, keyed by index.
+	GetAliases(ctx context.Context, indexName string) (map[string][]string, error)
+
+	// AddAlias adds an alias to an index.
+	AddAlias(ctx context.Context, indexName, alias string) error
+
+	// RemoveAlias removes an alias from an index.
+	RemoveAlias(ctx context.Context, indexName, alias string) error
+
+	// SwapAlias atomically moves an alias from the indexes it points to onto
+	// indexName, and returns the indexes it was removed from.
+	SwapAlias(ctx context.Context, alias, indexName string) ([]string, error)
+
+	// PutTemplate creates or replaces an index template, using composable
+	// templates on ES >= 7.8 and legacy templates before.
+	PutTemplate(ctx context.Context, name string, tmpl IndexTemplate) error
+
+	// GetTemplate returns an index template, or nil if it doesn't exist.
+	GetTemplate(ctx context.Context, name string) (*IndexTemplate, error)
+
+	// GetTemplateNames returns the names of all index templates.
+	GetTemplateNames(ctx context.Context) ([]string, error)
+
+	// DeleteTemplate deletes an index template.
+	DeleteTemplate(ctx context.Context, name string) error
+
+	// GetSettings returns the settings of an index, with flat keys without
+	// the "index." prefix.
+	GetSettings(ctx context.Context, indexName string) (map[string]interface{}, error)
+
+	// PutSettings updates the dynamic settings of an index.
+	PutSettings(ctx context.Context, indexName string, settings map[string]interface{}) error
+
+	// GetMappings returns the mappings of an index.
+	GetMappings(ctx context.Context, indexName string) (map[string]interface{}, error)
+
+	// PutMapping adds fields to the mappings of an index. The mappings are
+	// given per type, or without types if they have "properties" at the top.
+	PutMapping(ctx context.Context, indexName string, mappings map[string]interface{}) error
+
+	// Open opens a closed index.
+	Open(ctx context.Context, indexName string) error
+
+	// Close closes an index, which keeps its data but frees its resources.
+	Close(ctx context.Context, indexName string) error
+
+	// Refresh makes the latest changes to an index searchable.
+	Refresh(ctx context.Context, indexName string) error
+
+	// Flush commits the latest changes to an index to disk.
+	Flush(ctx context.Context, indexName string) error
+
+	// ForceMerge merges the segments of an index down to maxSegments, or
+	// as far as ES sees fit if maxSegments is 0.
+	ForceMerge(ctx context.Context, indexName string, maxSegments int) error
+
+	// Shrink copies an index into a new index with fewer shards. The source is
+	// read only while shrunk, and its write block is restored after. Every
+	// shard of the source must have a copy on the same node. ES >= 5.0.
+	Shrink(ctx context.Context, source, target string, flags ShrinkFlags) error
+
+	// Rollover points an alias at a new index if the conditions in flags
+	// are met. ES >= 5.0.
+	Rollover(ctx context.Context, alias string, flags RolloverFlags) (*RolloverResult, error)
+
+	// ClearCache clears the caches of an index.
+	ClearCache(ctx context.Context, indexName string, flags ClearCacheFlags) error
 }
 
 type indexManager struct {
 	client        *elastic.Client
 	indexSettings jsonMap
 	esVersion     ESVersion
+
+	// temporary holds the replicas of the temporary indexes created by this
+	// manager, for those without a _meta to keep them in
+	mu        sync.Mutex
+	temporary map[string]interface{}
 }
 
+// replicasMeta is the key of the mapping _meta where a temporary index keeps
+// the number of replicas it was created without, until made permanent
+const replicasMeta = "esu_number_of_replicas"
+
 func NewIndexManager(
 	client *elastic.Client,
 	indexSettings *json.RawMessage) (IndexManager, error) {
@@ -69,56 +190,90 @@ func NewIndexManager(
 		client:        client,
 		indexSettings: settings,
 		esVersion:     esVersion,
+		temporary:     map[string]interface{}{},
 	}, nil
 }
 
 func (mgr *indexManager) Create(
+	ctx context.Context,
 	indexName string,
 	flags CreateFlags,
 	mappings interface{}) error {
-	ctx := context.Background()
-
 	logger.Infof("Creating index %q", indexName)
 
-	settings := mgr.indexSettings.copy()
+	settings := mgr.indexSettings.copy().merge(flags.Settings)
+	if flags.Shards != nil {
+		settings["number_of_shards"] = *flags.Shards
+	}
+	if flags.Replicas != nil {
+		settings["number_of_replicas"] = *flags.Replicas
+	}
 	if flags.Temporary {
+		if replicas, ok := settings["number_of_replicas"]; ok {
+			mgr.mu.Lock()
+			mgr.temporary[indexName] = replicas
+			mgr.mu.Unlock()
+
+			// Kept on the index as well, as it may be made permanent by another process
+			var err error
+			if mappings, err = mgr.withMeta(mappings, replicasMeta, replicas); err != nil {
+				return errors.Wrapf(err, "Unable to create index %q", indexName)
+			}
+		}
 		settings["number_of_replicas"] = 0
 		settings["refresh_interval"] = -1
 		settings["translog"] = jsonMap{"durability": "async"}
 	}
 
-	_, err := mgr.client.CreateIndex(indexName).
-		BodyJson(jsonMap{
-			"settings": jsonMap{
-				"index": settings,
-			},
-			"mappings": mappings,
-		}).Do(ctx)
+	body := jsonMap{
+		"settings": jsonMap{
+			"index": settings,
+		},
+		"mappings": mappings,
+	}
+	if len(flags.Aliases) > 0 {
+		aliases := jsonMap{}
+		for _, alias := range flags.Aliases {
+			aliases[alias] = jsonMap{}
+		}
+		body["aliases"] = aliases
+	}
+
+	timeout := flags.Timeout
+	if timeout == "" {
+		timeout = "30s"
+	}
+	params := url.Values{"timeout": []string{timeout}}
+	if flags.WaitForActiveShards != "" {
+		params.Set("wait_for_active_shards", flags.WaitForActiveShards)
+	}
+
+	_, err := mgr.client.PerformRequest(ctx, "PUT", "/"+url.PathEscape(indexName), params, body)
 	if err != nil {
 		logger.Errorf("Could not create index %q, escalating: %s", indexName, err)
 		return errors.Wrapf(err, "Unable to create index %q", indexName)
 	}
 
-	logger.Infof("Waiting for newly created index %q", indexName)
+	status := flags.WaitForStatus
+	if status == "" {
+		status = "yellow"
+	}
+	if status != "none" {
+		logger.Infof("Waiting for newly created index %q", indexName)
 
-	_, err = mgr.client.ClusterHealth().
-		Timeout("30s").
-		Index(indexName).
-		WaitForYellowStatus().Do(ctx)
-	if err != nil {
-		return errors.Wrapf(err,
-			"Created index %q, but timed out waiting for cluster status to turn yellow or green",
-			indexName)
+		if err := mgr.waitForStatus(ctx, indexName, status, timeout); err != nil {
+			return errors.Wrapf(err, "Created index %q", indexName)
+		}
 	}
 
 	logger.Infof("Created index %q", indexName)
 	return nil
 }
 
-func (mgr *indexManager) Delete(indexName string) error {
+func (mgr *indexManager) Delete(ctx context.Context, indexName string) error {
 	logger.Infof("Deleting index %q", indexName)
 
-	_, err := mgr.client.DeleteIndex(indexName).Do(context.Background())
+	_, err := mgr.client.DeleteIndex(indexName).Do(ctx)
 	if err != nil {
 		if IsElasticErrorOfType(err, "index_not_found_exception") {
 			logger.Infof("Index %q did not exist", indexName)
@@ -131,9 +286,9 @@ func (mgr *indexManager) Delete(indexName string) error {
 	return nil
 }
 
-func (mgr *indexManager) GetNames() ([]string, error) {
-	// resp, err := mgr.client.IndexGet("*").AllowNoIndexes(true).Do(context.Background())
-	resp, err := mgr.client.IndexGet().Index("*").IgnoreUnavailable(true).Do(context.Background())
+func (mgr *indexManager) GetNames(ctx context.Context) ([]string, error) {
+	// resp, err := mgr.client.IndexGet("*").AllowNoIndexes(true).Do(ctx)
+	resp, err := mgr.client.IndexGet().Index("*").IgnoreUnavailable(true).Do(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "Could not get indexes")
 	}
@@ -147,39 +302,324 @@ func (mgr *indexManager) GetNames() ([]string, error) {
 	return names, nil
 }
 
-func (mgr *indexManager) MakePermanent(indexName string) error {
-	ctx := context.Background()
-
+func (mgr *indexManager) MakePermanent(ctx context.Context, indexName string, flags PermanentFlags) error {
 	logger.Infof("Finalizing settings of index %q", indexName)
 
+	mappings, err := mgr.GetMappings(ctx, indexName)
+	if err != nil {
+		return err
+	}
+	replicas, err := mgr.permanentReplicas(ctx, indexName, mappings, flags)
+	if err != nil {
+		return err
+	}
+	settings := mgr.getPermanentIndexSettings()
+	settings["number_of_replicas"] = replicas
+
 	if _, err := mgr.client.IndexPutSettings(indexName).
 		BodyJson(jsonMap{
-			"index": mgr.getPermanentIndexSettings(),
+			"index": settings,
 		}).Do(ctx); err != nil {
+		return errors.Wrapf(err, "Unable to update settings of index %q", indexName)
+	}
+	if err := mgr.removeMeta(ctx, indexName, mappings, replicasMeta); err != nil {
 		return err
 	}
 
 	logger.Infof("Flushing index %q", indexName)
 	if _, err := mgr.client.Flush(indexName).IgnoreUnavailable(true).Do(ctx); err != nil {
-		logger.Warningf("Unable to flush index %q, ignoring: %s", err)
+		logger.Warningf("Unable to flush index %q, ignoring: %s", indexName, err)
+	}
+
+	status, timeout := flags.WaitForStatus, flags.Timeout
+	if status == "" {
+		status = "yellow"
+	}
+	if timeout == "" {
+		timeout = "30s"
+	}
+	logger.Infof("Waiting for index %q to turn %s", indexName, status)
+	if err := mgr.waitForStatus(ctx, indexName, status, timeout); err != nil {
+		return errors.Wrapf(err, "Made index %q permanent", indexName)
 	}
 
 	return nil
 }
 
-func (mgr *indexManager) IndexExists(indexName string) (bool, error) {
-	ctx := context.Background()
+// permanentReplicas decides the number of replicas of an index made permanent:
+// the one in flags, the one it was created without, the one in the index
+// settings of the manager, the one of the matching templates, or else 1.
+func (mgr *indexManager) permanentReplicas(ctx context.Context, indexName string, mappings map[string]interface{}, flags PermanentFlags) (interface{}, error) {
+	mgr.mu.Lock()
+	created, wasCreated := mgr.temporary[indexName]
+	delete(mgr.temporary, indexName)
+	mgr.mu.Unlock()
+
+	if flags.Replicas != nil {
+		return *flags.Replicas, nil
+	}
+	if replicas, ok := mappingMeta(mappings, replicasMeta); ok {
+		return replicas, nil
+	}
+	if wasCreated {
+		return created, nil
+	}
+	if replicas, ok := mgr.indexSettings["number_of_replicas"]; ok {
+		return replicas, nil
+	}
+
+	if mgr.composableTemplates() {
+		templates, err := mgr.getTemplates(ctx, "", true)
+		if err != nil {
+			return nil, err
+		}
+		if anyTemplateMatches(indexName, templates) {
+			return templateReplicas(indexName, templates, true), nil
+		}
+	}
+	// Legacy templates still apply when no composable template matches
+	templates, err := mgr.getTemplates(ctx, "", false)
+	if err != nil {
+		return nil, err
+	}
+	return templateReplicas(indexName, templates, false), nil
+}
+
+// withMeta returns a copy of mappings with key set in the _meta of the
+// mappings, or of every type in them before ES 7.0. Mappings without types
+// before ES 7.0 are left as they are, as there is no _meta to keep it in.
+func (mgr *indexManager) withMeta(mappings interface{}, key string, value interface{}) (interface{}, error) {
+	m := map[string]interface{}{}
+	if mappings != nil {
+		raw, err := json.Marshal(mappings)
+		if err != nil {
+			return nil, errors.Wrap(err, "Invalid mappings")
+		}
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return nil, errors.Wrap(err, "Invalid mappings")
+		}
+	}
+
+	setMeta := func(mapping map[string]interface{}) {
+		meta, _ := mapping["_meta"].(map[string]interface{})
+		if meta == nil {
+			meta = map[string]interface{}{}
+		}
+		meta[key] = value
+		mapping["_meta"] = meta
+	}
+
+	if mgr.esVersion.AtLeast(7, 0) {
+		setMeta(m)
+		return m, nil
+	}
+	for _, mapping := range m {
+		if mapping, ok := mapping.(map[string]interface{}); ok {
+			setMeta(mapping)
+		}
+	}
+	return m, nil
+}
+
+// removeMeta deletes key from the _meta of the mappings of an index, or of
+// every type in them before ES 7.0. The rest of the mappings are left alone.
+func (mgr *indexManager) removeMeta(ctx context.Context, indexName string, mappings map[string]interface{}, key string) error {
+	without := func(mapping interface{}) (jsonMap, bool) {
+		m, _ := mapping.(map[string]interface{})
+		meta, _ := m["_meta"].(map[string]interface{})
+		if _, ok := meta[key]; !ok {
+			return nil, false
+		}
+		rest := jsonMap{}
+		for k, v := range meta {
+			if k != key {
+				rest[k] = v
+			}
+		}
+		// _meta is replaced as a whole
+		return jsonMap{"_meta": rest}, true
+	}
+
+	if mgr.esVersion.AtLeast(7, 0) {
+		put, ok := without(mappings)
+		if !ok {
+			return nil
+		}
+		if _, err := mgr.client.PerformRequest(ctx, "PUT", "/"+url.PathEscape(indexName)+"/_mapping", nil, put); err != nil {
+			return errors.Wrapf(err, "Unable to update mappings of index %q", indexName)
+		}
+		return nil
+	}
+
+	put := map[string]interface{}{}
+	for typ, mapping := range mappings {
+		if m, ok := without(mapping); ok {
+			put[typ] = m
+		}
+	}
+	if len(put) == 0 {
+		return nil
+	}
+	return mgr.PutMapping(ctx, indexName, put)
+}
+
+// mappingMeta looks up key in the _meta of mappings, or of any type in them
+func mappingMeta(mappings map[string]interface{}, key string) (interface{}, bool) {
+	if meta, ok := mappings["_meta"].(map[string]interface{}); ok {
+		if v, ok := meta[key]; ok {
+			return v, true
+		}
+	}
+	for _, mapping := range mappings {
+		mapping, _ := mapping.(map[string]interface{})
+		if meta, ok := mapping["_meta"].(map[string]interface{}); ok {
+			if v, ok := meta[key]; ok {
+				return v, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// anyTemplateMatches tells whether any of templates applies to indexName
+func anyTemplateMatches(indexName string, templates map[string]IndexTemplate) bool {
+	for _, tmpl := range templates {
+		if matchesAny(indexName, tmpl.IndexPatterns) {
+			return true
+		}
+	}
+	return false
+}
+
+// templateReplicas returns the number of replicas templates give an index.
+// Legacy templates are merged by priority, while only the composable template
+// with the highest priority applies. Templates of the same priority are
+// ordered by name, so the result doesn't depend on map order.
+func templateReplicas(indexName string, templates map[string]IndexTemplate, composable bool) interface{} {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := templates[names[i]], templates[names[j]]
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+		return names[i] < names[j]
+	})
+
+	var replicas interface{} = 1
+	for _, name := range names {
+		tmpl := templates[name]
+		if !matchesAny(indexName, tmpl.IndexPatterns) {
+			continue
+		}
+
+		settings := map[string]interface{}{}
+		flattenSettings("", tmpl.Settings, settings)
+		if r, ok := settings["number_of_replicas"]; ok {
+			replicas = r
+		} else if composable {
+			replicas = 1
+		}
+	}
+	return replicas
+}
+
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// waitForStatus waits for the health of an index to reach status
+func (mgr *indexManager) waitForStatus(ctx context.Context, indexName, status, timeout string) error {
+	resp, err := mgr.client.ClusterHealth().
+		Index(indexName).
+		WaitForStatus(status).
+		Timeout(timeout).
+		Do(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "Timed out waiting for index %q to turn %s", indexName, status)
+	}
+	if resp.TimedOut {
+		return errors.Errorf("Timed out waiting for index %q to turn %s, it is %s", indexName, status, resp.Status)
+	}
+	return nil
+}
+
+func (mgr *indexManager) IndexExists(ctx context.Context, indexName string) (bool, error) {
 	return mgr.client.IndexExists(indexName).Do(ctx)
 }
 
+func (mgr *indexManager) GetSettings(ctx context.Context, indexName string) (map[string]interface{}, error) {
+	resp, err := mgr.client.IndexGetSettings(indexName).FlatSettings(true).Do(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not get settings of index %q", indexName)
+	}
+
+	settings := map[string]interface{}{}
+	if res, ok := resp[indexName]; ok && res != nil {
+		for k, v := range res.Settings {
+			settings[strings.TrimPrefix(k, "index.")] = v
+		}
+	}
+	return settings, nil
+}
+
+func (mgr *indexManager) PutSettings(ctx context.Context, indexName string, settings map[string]interface{}) error {
+	logger.Infof("Updating settings of index %q", indexName)
+
+	resp, err := mgr.client.IndexPutSettings(indexName).
+		BodyJson(jsonMap{"index": settings}).
+		Do(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to update settings of index %q", indexName)
+	}
+	if !resp.Acknowledged {
+		return errors.Wrapf(ErrNotAcknowledged, "Unable to update settings of index %q", indexName)
+	}
+	return nil
+}
+
+func (mgr *indexManager) GetMappings(ctx context.Context, indexName string) (map[string]interface{}, error) {
+	resp, err := mgr.client.GetMapping().Index(indexName).Do(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not get mappings of index %q", indexName)
+	}
+
+	if index, ok := resp[indexName].(map[string]interface{}); ok {
+		if mappings, ok := index["mappings"].(map[string]interface{}); ok {
+			return mappings, nil
+		}
+	}
+	return map[string]interface{}{}, nil
+}
+
+func (mgr *indexManager) PutMapping(ctx context.Context, indexName string, mappings map[string]interface{}) error {
+	logger.Infof("Updating mappings of index %q", indexName)
+
+	if _, ok := mappings["properties"]; ok {
+		if _, err := mgr.client.PerformRequest(ctx, "PUT", "/"+url.PathEscape(indexName)+"/_mapping", nil, mappings); err != nil {
+			return errors.Wrapf(err, "Unable to update mappings of index %q", indexName)
+		}
+		return nil
+	}
+
+	for typ, mapping := range mappings {
+		if _, err := mgr.client.PerformRequest(ctx, "PUT", "/"+url.PathEscape(indexName)+"/_mapping/"+url.PathEscape(typ), nil, mapping); err != nil {
+			return errors.Wrapf(err, "Unable to update mappings of type %q in index %q", typ, indexName)
+		}
+	}
+	return nil
+}
+
 func (mgr *indexManager) getPermanentIndexSettings() jsonMap {
 	settings := jsonMap{}
 
-	// For all versions of ES, there's no way to find the default. For ES >= 5.0, this
-	// is not a problem, as the global index settings are removed, and the default is
-	// always the same. For < 5.0, it might be wrong.
-	settings["number_of_replicas"] = 5
-
 	if mgr.esVersion[0] >= 5 {
 		// For ES >= 5.0, we can simply delete the temporary settings
 		settings["refresh_interval"] = nil
@@ -201,28 +641,50 @@ func (mgr *indexManager) getPermanentIndexSettings() jsonMap {
 
 type ESVersion []int
 
+// AtLeast tells whether the version is major.minor or later
+func (v ESVersion) AtLeast(major, minor int) bool {
+	if len(v) == 0 {
+		return false
+	}
+	if v[0] != major {
+		return v[0] > major
+	}
+	if len(v) == 1 {
+		return minor == 0
+	}
+	return v[1] >= minor
+}
+
+func (v ESVersion) String() string {
+	parts := make([]string, len(v))
+	for i, n := range v {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ".")
+}
+
 func DetectVersion(client *elastic.Client) (ESVersion, error) {
 	resp, err := client.NodesInfo().NodeId("_local").Do(context.Background())
 	if err != nil {
 		return nil, errors.Wrap(err, "Error while detecting Elasticsearch version")
 	}
 	for _, node := range resp.Nodes {
-		return parseVersion(node.Version), nil
+		return parseVersion(node.Version)
 	}
-	panic("Elasticsearch node list unexpectedly empty")
+	return nil, ErrNoNodes
 }
 
-func parseVersion(s string) (version ESVersion) {
+func parseVersion(s string) (ESVersion, error) {
 	parts := strings.Split(s, ".")
-	version = make(ESVersion, len(parts))
+	version := make(ESVersion, len(parts))
 	for i, part := range parts {
 		n, err := strconv.ParseInt(part, 10, 32)
 		if err != nil {
-			panic(fmt.Sprintf("ES returned invalid version: %q", s))
+			return nil, &InvalidVersionError{Version: s}
 		}
 		version[i] = int(n)
 	}
-	return
+	return version, nil
 }
 
 func IsElasticErrorOfType(err error, exceptionType string) bool {
@@ -230,7 +692,7 @@ func IsElasticErrorOfType(err error, exceptionType string) bool {
 		return false
 	}
 
-	if e, ok := err.(*elastic.Error); ok {
+	if e, ok := errors.Cause(err).(*elastic.Error); ok {
 		return e.Details != nil && e.Details.Type == exceptionType
 	}
 	return false
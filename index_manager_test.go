@@ -0,0 +1,709 @@
+package esu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// fakeCluster is a minimal stand-in for the Elasticsearch index APIs used by IndexManager
+type fakeCluster struct {
+	mu        sync.Mutex
+	version   string
+	indices   map[string]*fakeIndex
+	templates map[string]jsonMap
+	calls     []string
+	health    []string
+}
+
+type fakeIndex struct {
+	settings jsonMap
+	mappings jsonMap
+	aliases  map[string]bool
+	query    url.Values // of the create request
+}
+
+func newFakeCluster(t *testing.T, version string) (*fakeCluster, IndexManager) {
+	es := &fakeCluster{version: version, indices: map[string]*fakeIndex{}, templates: map[string]jsonMap{}}
+	srv := httptest.NewServer(es)
+	t.Cleanup(srv.Close)
+
+	cn, err := NewByUrl(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr, err := NewIndexManager(cn.Client, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return es, mgr
+}
+
+func (es *fakeCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	var body jsonMap
+	json.NewDecoder(r.Body).Decode(&body)
+	// Split the escaped path, so that an escaped / stays in its segment
+	parts := strings.Split(strings.Trim(r.URL.EscapedPath(), "/"), "/")
+	for i, part := range parts {
+		parts[i], _ = url.PathUnescape(part)
+	}
+	call := r.Method + " " + r.URL.EscapedPath()
+	if r.URL.RawQuery != "" {
+		call += "?" + r.URL.RawQuery
+	}
+	es.calls = append(es.calls, call)
+
+	w.Header().Set("Content-Type", "application/json")
+	reply := func(v interface{}) { json.NewEncoder(w).Encode(v) }
+	acknowledged := jsonMap{"acknowledged": true}
+
+	switch {
+	case parts[0] == "_nodes":
+		reply(jsonMap{"nodes": jsonMap{"n1": jsonMap{"version": es.version}}})
+	case parts[0] == "_template" || parts[0] == "_index_template":
+		es.template(w, r, parts, body)
+	case parts[0] == "_cluster":
+		es.health = append(es.health, r.URL.Query().Get("wait_for_status"))
+		reply(jsonMap{"status": "green"})
+	case r.URL.Path == "/_aliases" && r.Method == "POST":
+		for _, action := range body["actions"].([]interface{}) {
+			for op, params := range action.(map[string]interface{}) {
+				params := params.(map[string]interface{})
+				index := es.indices[params["index"].(string)]
+				if index == nil {
+					w.WriteHeader(http.StatusNotFound)
+					reply(jsonMap{"error": jsonMap{"type": "index_not_found_exception"}, "status": 404})
+					return
+				}
+				index.aliases[params["alias"].(string)] = op == "add"
+			}
+		}
+		reply(acknowledged)
+	case parts[len(parts)-1] == "_aliases":
+		res := jsonMap{}
+		for name, index := range es.indices {
+			if len(parts) == 2 && parts[0] != name {
+				continue
+			}
+			aliases := jsonMap{}
+			for alias, ok := range index.aliases {
+				if ok {
+					aliases[alias] = jsonMap{}
+				}
+			}
+			res[name] = jsonMap{"aliases": aliases}
+		}
+		reply(res)
+	case len(parts) == 2 && parts[1] == "_settings" && r.Method == "GET":
+		settings := jsonMap{}
+		for k, v := range es.indices[parts[0]].settings {
+			settings["index."+k] = fmt.Sprint(v)
+		}
+		reply(jsonMap{parts[0]: jsonMap{"settings": settings}})
+	case len(parts) == 2 && parts[1] == "_settings":
+		index := es.indices[parts[0]]
+		index.settings = index.settings.merge(jsonMap(body["index"].(map[string]interface{})))
+		reply(acknowledged)
+	case len(parts) >= 2 && parts[1] == "_mapping" && r.Method == "GET":
+		reply(jsonMap{parts[0]: jsonMap{"mappings": es.indices[parts[0]].mappings}})
+	case len(parts) == 3 && parts[1] == "_mapping":
+		index := es.indices[parts[0]]
+		typ, _ := index.mappings[parts[2]].(map[string]interface{})
+		index.mappings[parts[2]] = mergeMapping(typ, body)
+		reply(acknowledged)
+	case len(parts) == 2 && parts[1] == "_mapping" && r.Method == "PUT":
+		index := es.indices[parts[0]]
+		index.mappings = jsonMap(mergeMapping(index.mappings, body))
+		reply(acknowledged)
+	case len(parts) == 2 && parts[1] == "_flush":
+		reply(jsonMap{"_shards": jsonMap{"total": 1, "successful": 1, "failed": 0}})
+	case len(parts) >= 2 && parts[1] == "_rollover":
+		reply(jsonMap{"old_index": "logs-1", "new_index": "logs-2", "rolled_over": r.URL.Query().Get("dry_run") == "", "dry_run": r.URL.Query().Get("dry_run") != "", "conditions": jsonMap{"[max_docs: 10]": true}})
+	case len(parts) == 3 && parts[1] == "_shrink" && fmt.Sprint(es.indices[parts[0]].settings["blocks.write"]) != "true":
+		w.WriteHeader(http.StatusBadRequest)
+		reply(jsonMap{"error": jsonMap{"type": "illegal_state_exception", "reason": "index " + parts[0] + " must be read-only to resize index"}, "status": 400})
+	case len(parts) == 3 && parts[1] == "_shrink":
+		es.indices[parts[2]] = &fakeIndex{settings: jsonMap(body["settings"].(map[string]interface{})["index"].(map[string]interface{})), mappings: jsonMap{}, aliases: map[string]bool{}}
+		reply(jsonMap{"acknowledged": true, "shards_acknowledged": true})
+	case len(parts) >= 2 && strings.HasPrefix(parts[1], "_"):
+		reply(jsonMap{"acknowledged": true, "_shards": jsonMap{"total": 1, "successful": 1, "failed": 0}})
+	case len(parts) == 1 && r.Method == "PUT":
+		index := &fakeIndex{settings: jsonMap{}, mappings: jsonMap{}, aliases: map[string]bool{}}
+		if settings, ok := body["settings"].(map[string]interface{}); ok {
+			if settings, ok := settings["index"].(map[string]interface{}); ok {
+				index.settings = jsonMap(settings)
+			}
+		}
+		if mappings, ok := body["mappings"].(map[string]interface{}); ok {
+			index.mappings = jsonMap(mappings)
+		}
+		if aliases, ok := body["aliases"].(map[string]interface{}); ok {
+			for alias := range aliases {
+				index.aliases[alias] = true
+			}
+		}
+		index.query = r.URL.Query()
+		es.indices[parts[0]] = index
+		reply(acknowledged)
+	case len(parts) == 1 && r.Method == "DELETE":
+		delete(es.indices, parts[0])
+		reply(acknowledged)
+	case len(parts) == 1 && r.Method == "HEAD":
+		if es.indices[parts[0]] == nil {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case len(parts) == 1 && r.Method == "GET":
+		res := jsonMap{}
+		for name := range es.indices {
+			res[name] = jsonMap{}
+		}
+		reply(res)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// mergeMapping merges mappings put on an index into the existing ones.
+// Like in ES, _meta is replaced as a whole.
+func mergeMapping(dst, src map[string]interface{}) map[string]interface{} {
+	if dst == nil {
+		dst = map[string]interface{}{}
+	}
+	for k, v := range src {
+		if m, ok := v.(map[string]interface{}); ok && k != "_meta" {
+			existing, _ := dst[k].(map[string]interface{})
+			dst[k] = mergeMapping(existing, m)
+			continue
+		}
+		dst[k] = v
+	}
+	return dst
+}
+
+// template serves the legacy and composable index template APIs, keeping
+// the bodies put as they are
+func (es *fakeCluster) template(w http.ResponseWriter, r *http.Request, parts []string, body jsonMap) {
+	composable := parts[0] == "_index_template"
+	key := func(name string) string { return parts[0] + "/" + name }
+
+	switch {
+	case r.Method == "PUT":
+		es.templates[key(parts[1])] = body
+		json.NewEncoder(w).Encode(jsonMap{"acknowledged": true})
+	case r.Method == "DELETE":
+		if es.templates[key(parts[1])] == nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(jsonMap{"error": jsonMap{"type": "index_template_missing_exception"}, "status": 404})
+			return
+		}
+		delete(es.templates, key(parts[1]))
+		json.NewEncoder(w).Encode(jsonMap{"acknowledged": true})
+	default:
+		found := map[string]jsonMap{}
+		for k, body := range es.templates {
+			name := strings.TrimPrefix(k, parts[0]+"/")
+			if strings.HasPrefix(k, parts[0]+"/") && (len(parts) == 1 || parts[1] == name) {
+				found[name] = body
+			}
+		}
+		if len(found) == 0 && len(parts) == 2 {
+			w.WriteHeader(http.StatusNotFound)
+			if composable {
+				json.NewEncoder(w).Encode(jsonMap{"error": jsonMap{"type": "resource_not_found_exception"}, "status": 404})
+			} else {
+				w.Write([]byte("{}"))
+			}
+			return
+		}
+
+		if !composable {
+			json.NewEncoder(w).Encode(found)
+			return
+		}
+		templates := []jsonMap{}
+		for name, body := range found {
+			templates = append(templates, jsonMap{"name": name, "index_template": body})
+		}
+		json.NewEncoder(w).Encode(jsonMap{"index_templates": templates})
+	}
+}
+
+func TestESVersion_AtLeast(t *testing.T) {
+	for _, tc := range []struct {
+		version      ESVersion
+		major, minor int
+		expected     bool
+	}{
+		{ESVersion{5, 6, 0}, 5, 0, true},
+		{ESVersion{5, 6, 0}, 5, 6, true},
+		{ESVersion{5, 6, 0}, 5, 7, false},
+		{ESVersion{5, 6, 0}, 6, 0, false},
+		{ESVersion{7, 0}, 6, 8, true},
+		{ESVersion{7}, 7, 0, true},
+		{ESVersion{7}, 7, 1, false},
+		{ESVersion{}, 1, 0, false},
+	} {
+		if actual := tc.version.AtLeast(tc.major, tc.minor); actual != tc.expected {
+			t.Errorf("expected %s at least %d.%d to be %v", tc.version, tc.major, tc.minor, tc.expected)
+		}
+	}
+}
+
+func TestIndexManager_Create(t *testing.T) {
+	ctx := context.Background()
+	es, mgr := newFakeCluster(t, "5.6.0")
+
+	shards, replicas := 3, 2
+	err := mgr.Create(ctx, "books", CreateFlags{
+		Shards:              &shards,
+		Replicas:            &replicas,
+		Aliases:             []string{"books", "library"},
+		WaitForActiveShards: "all",
+		WaitForStatus:       "green",
+		Timeout:             "1m",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index := es.indices["books"]
+	if fmt.Sprint(index.settings["number_of_shards"], index.settings["number_of_replicas"]) != "3 2" {
+		t.Errorf("expected 3 shards and 2 replicas, got %v", index.settings)
+	}
+	if !index.aliases["books"] || !index.aliases["library"] {
+		t.Errorf("expected aliases books and library, got %v", index.aliases)
+	}
+	if index.query.Get("wait_for_active_shards") != "all" || index.query.Get("timeout") != "1m" {
+		t.Errorf("unexpected create parameters %v", index.query)
+	}
+	if len(es.health) != 1 || es.health[0] != "green" {
+		t.Errorf("expected to wait for green, got %v", es.health)
+	}
+
+	es.health = nil
+	if err := mgr.Create(ctx, "logs", CreateFlags{WaitForStatus: "none"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(es.health) != 0 || es.indices["logs"].query.Get("timeout") != "30s" {
+		t.Errorf("expected no wait and the default timeout, got %v and %v", es.health, es.indices["logs"].query)
+	}
+
+	// Date math names hold characters that must be escaped in the path
+	if err := mgr.Create(ctx, "<logs-{now/d}>", CreateFlags{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if es.indices["<logs-{now/d}>"] == nil {
+		t.Errorf("expected the date math index to be created, got %v", es.calls)
+	}
+}
+
+func TestIndexManager_PutMapping(t *testing.T) {
+	es, mgr := newFakeCluster(t, "5.6.0")
+	name := "<books-{now/d}>"
+	es.indices[name] = &fakeIndex{settings: jsonMap{}, mappings: jsonMap{}, aliases: map[string]bool{}}
+
+	err := mgr.PutMapping(context.Background(), name, map[string]interface{}{
+		"doc": map[string]interface{}{"properties": map[string]interface{}{"title": map[string]interface{}{"type": "text"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mappings, err := mgr.GetMappings(context.Background(), name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := mappings["doc"]; !ok {
+		t.Errorf("expected the doc mapping on %q, got %v", name, mappings)
+	}
+}
+
+func TestIndexManager_Aliases(t *testing.T) {
+	ctx := context.Background()
+	_, mgr := newFakeCluster(t, "5.6.0")
+	for _, name := range []string{"books_v1", "books_v2"} {
+		if err := mgr.Create(ctx, name, CreateFlags{}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := mgr.AddAlias(ctx, "books_v1", "books"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.AddAlias(ctx, "books_v1", "library"); err != nil {
+		t.Fatal(err)
+	}
+
+	previous, err := mgr.SwapAlias(ctx, "books", "books_v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(previous, []string{"books_v1"}) {
+		t.Errorf("expected alias to move from books_v1, got %v", previous)
+	}
+
+	if err := mgr.RemoveAlias(ctx, "books_v1", "library"); err != nil {
+		t.Fatal(err)
+	}
+
+	aliases, err := mgr.GetAliases(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]string{"books_v1": {}, "books_v2": {"books"}}
+	if !reflect.DeepEqual(aliases, expected) {
+		t.Errorf("expected aliases %v, got %v", expected, aliases)
+	}
+
+	if err := mgr.AddAlias(ctx, "missing", "books"); !IsElasticErrorOfType(err, "index_not_found_exception") {
+		t.Errorf("expected index_not_found_exception, got %v", err)
+	}
+}
+
+func TestRebuild(t *testing.T) {
+	ctx := context.Background()
+	es, mgr := newFakeCluster(t, "5.6.0")
+	if err := mgr.Create(ctx, "books_v1", CreateFlags{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.AddAlias(ctx, "books_v1", "books"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed fill leaves the alias alone and removes the new index
+	err := Rebuild(ctx, mgr, "books", "books_v2", nil, func(string) error {
+		return errors.New("boom")
+	}, RebuildFlags{DeleteOld: true})
+	if err == nil || es.indices["books_v2"] != nil || !es.indices["books_v1"].aliases["books"] {
+		t.Fatalf("expected failed rebuild to be rolled back, got %v", err)
+	}
+
+	var filled string
+	err = Rebuild(ctx, mgr, "books", "books_v2", nil, func(indexName string) error {
+		filled = indexName
+		if es.indices[indexName].settings["refresh_interval"] != -1.0 {
+			t.Errorf("expected %q to be temporary while filled", indexName)
+		}
+		return nil
+	}, RebuildFlags{DeleteOld: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if filled != "books_v2" || es.indices["books_v1"] != nil || !es.indices["books_v2"].aliases["books"] {
+		t.Errorf("expected alias on books_v2 and books_v1 deleted, got %v", es.calls)
+	}
+	if es.indices["books_v2"].settings["refresh_interval"] != nil {
+		t.Errorf("expected books_v2 to be permanent, got %v", es.indices["books_v2"].settings)
+	}
+}
+
+func TestIndexManager_Templates(t *testing.T) {
+	ctx := context.Background()
+	for version, expected := range map[string]string{
+		"5.6.0":  `{"order":2,"settings":{"number_of_shards":1},"template":"logs-*"}`,
+		"6.8.0":  `{"index_patterns":["logs-*"],"order":2,"settings":{"number_of_shards":1}}`,
+		"7.10.0": `{"index_patterns":["logs-*"],"priority":2,"template":{"settings":{"number_of_shards":1}}}`,
+	} {
+		es, mgr := newFakeCluster(t, version)
+
+		tmpl := IndexTemplate{
+			IndexPatterns: []string{"logs-*"},
+			Priority:      2,
+			Settings:      map[string]interface{}{"number_of_shards": 1.0},
+		}
+		if err := mgr.PutTemplate(ctx, "logs", tmpl); err != nil {
+			t.Fatal(err)
+		}
+		for _, body := range es.templates {
+			if actual, _ := json.Marshal(body); string(actual) != expected {
+				t.Errorf("%s: expected template %s, got %s", version, expected, actual)
+			}
+		}
+
+		got, err := mgr.GetTemplate(ctx, "logs")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, &tmpl) {
+			t.Errorf("%s: expected template %+v, got %+v", version, tmpl, got)
+		}
+
+		names, err := mgr.GetTemplateNames(ctx)
+		if err != nil || !reflect.DeepEqual(names, []string{"logs"}) {
+			t.Errorf("%s: expected template names [logs], got %v (%v)", version, names, err)
+		}
+
+		if err := mgr.DeleteTemplate(ctx, "logs"); err != nil {
+			t.Fatal(err)
+		}
+		if err := mgr.DeleteTemplate(ctx, "logs"); err != nil {
+			t.Errorf("%s: expected deleting a missing template to succeed, got %v", version, err)
+		}
+		if got, err := mgr.GetTemplate(ctx, "logs"); got != nil || err != nil {
+			t.Errorf("%s: expected no template, got %+v (%v)", version, got, err)
+		}
+	}
+
+	_, mgr := newFakeCluster(t, "5.6.0")
+	if err := mgr.PutTemplate(ctx, "logs", IndexTemplate{IndexPatterns: []string{"a-*", "b-*"}}); err == nil {
+		t.Error("expected several patterns to be rejected before ES 6.0")
+	}
+}
+
+func TestIndexManager_MakePermanent(t *testing.T) {
+	ctx := context.Background()
+	es, mgr := newFakeCluster(t, "5.6.0")
+	err := mgr.PutTemplate(ctx, "logs", IndexTemplate{
+		IndexPatterns: []string{"logs-*"},
+		Settings:      map[string]interface{}{"index": map[string]interface{}{"number_of_replicas": 3}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zero := 0
+	for _, tc := range []struct {
+		index    string
+		settings map[string]interface{}
+		flags    PermanentFlags
+		expected string
+	}{
+		{"books", map[string]interface{}{"number_of_replicas": 2}, PermanentFlags{}, "2"},
+		{"logs-1", nil, PermanentFlags{}, "3"},
+		{"logs-2", nil, PermanentFlags{Replicas: &zero}, "0"},
+		{"other", nil, PermanentFlags{WaitForStatus: "green"}, "1"},
+	} {
+		if err := mgr.Create(ctx, tc.index, CreateFlags{Temporary: true, Settings: tc.settings}, nil); err != nil {
+			t.Fatal(err)
+		}
+		if replicas := fmt.Sprint(es.indices[tc.index].settings["number_of_replicas"]); replicas != "0" {
+			t.Errorf("%s: expected temporary index without replicas, got %s", tc.index, replicas)
+		}
+
+		es.health = nil
+		if err := mgr.MakePermanent(ctx, tc.index, tc.flags); err != nil {
+			t.Fatal(err)
+		}
+		settings := es.indices[tc.index].settings
+		if replicas := fmt.Sprint(settings["number_of_replicas"]); replicas != tc.expected {
+			t.Errorf("%s: expected %s replicas, got %s", tc.index, tc.expected, replicas)
+		}
+		if settings["refresh_interval"] != nil {
+			t.Errorf("%s: expected refresh interval to be reset, got %v", tc.index, settings["refresh_interval"])
+		}
+
+		expected := tc.flags.WaitForStatus
+		if expected == "" {
+			expected = "yellow"
+		}
+		if len(es.health) != 1 || es.health[0] != expected {
+			t.Errorf("%s: expected to wait for %s, got %v", tc.index, expected, es.health)
+		}
+	}
+}
+
+func TestIndexManager_MakePermanentFreshManager(t *testing.T) {
+	ctx := context.Background()
+	two := 2
+	for version, mappings := range map[string]interface{}{
+		"5.6.0":  map[string]interface{}{"doc": map[string]interface{}{}},
+		"6.8.0":  map[string]interface{}{"doc": map[string]interface{}{"properties": map[string]interface{}{}}},
+		"7.10.0": map[string]interface{}{"properties": map[string]interface{}{}},
+	} {
+		es, mgr := newFakeCluster(t, version)
+		if err := mgr.Create(ctx, "books", CreateFlags{Temporary: true, Replicas: &two}, mappings); err != nil {
+			t.Fatal(err)
+		}
+
+		// Made permanent by another process, which only knows the index
+		fresh, err := NewIndexManager(mgr.(*indexManager).client, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := fresh.MakePermanent(ctx, "books", PermanentFlags{}); err != nil {
+			t.Fatal(err)
+		}
+		if replicas := fmt.Sprint(es.indices["books"].settings["number_of_replicas"]); replicas != "2" {
+			t.Errorf("%s: expected the 2 replicas the index was created with, got %s", version, replicas)
+		}
+		if _, ok := mappingMeta(es.indices["books"].mappings, replicasMeta); ok {
+			t.Errorf("%s: expected the replicas to be removed from the mappings, got %v", version, es.indices["books"].mappings)
+		}
+	}
+}
+
+func TestIndexManager_MakePermanentWithoutTypes(t *testing.T) {
+	ctx := context.Background()
+	two := 2
+	es, mgr := newFakeCluster(t, "5.6.0")
+	if err := mgr.Create(ctx, "books", CreateFlags{Temporary: true, Replicas: &two}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(es.indices["books"].mappings) != 0 {
+		t.Errorf("expected the mappings to be left alone, got %v", es.indices["books"].mappings)
+	}
+
+	// Only the manager that created the index knows its replicas
+	if err := mgr.MakePermanent(ctx, "books", PermanentFlags{}); err != nil {
+		t.Fatal(err)
+	}
+	if replicas := fmt.Sprint(es.indices["books"].settings["number_of_replicas"]); replicas != "2" {
+		t.Errorf("expected the 2 replicas the index was created with, got %s", replicas)
+	}
+}
+
+func TestIndexManager_MakePermanentLegacyTemplates(t *testing.T) {
+	ctx := context.Background()
+	es, mgr := newFakeCluster(t, "7.10.0")
+	es.templates["_template/logs"] = jsonMap{"index_patterns": []interface{}{"logs-*"}, "settings": jsonMap{"number_of_replicas": 3}}
+	es.templates["_index_template/metrics"] = jsonMap{"index_patterns": []interface{}{"metrics-*"}, "template": jsonMap{"settings": jsonMap{"number_of_replicas": 2}}}
+
+	for index, expected := range map[string]string{"logs-1": "3", "metrics-1": "2", "other": "1"} {
+		if err := mgr.Create(ctx, index, CreateFlags{Temporary: true}, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := mgr.MakePermanent(ctx, index, PermanentFlags{}); err != nil {
+			t.Fatal(err)
+		}
+		if replicas := fmt.Sprint(es.indices[index].settings["number_of_replicas"]); replicas != expected {
+			t.Errorf("%s: expected %s replicas, got %s", index, expected, replicas)
+		}
+	}
+}
+
+func TestTemplateReplicas(t *testing.T) {
+	templates := map[string]IndexTemplate{
+		"all":  {IndexPatterns: []string{"*"}, Priority: 0, Settings: map[string]interface{}{"number_of_replicas": 2}},
+		"logs": {IndexPatterns: []string{"logs-*"}, Priority: 5, Settings: map[string]interface{}{"number_of_shards": 1}},
+	}
+
+	// Legacy templates merge, so the replicas of the lower priority template apply
+	if replicas := templateReplicas("logs-1", templates, false); replicas != 2 {
+		t.Errorf("expected 2 replicas from legacy templates, got %v", replicas)
+	}
+	// Only the composable template with the highest priority applies
+	if replicas := templateReplicas("logs-1", templates, true); replicas != 1 {
+		t.Errorf("expected the default of 1 replica from composable templates, got %v", replicas)
+	}
+	if replicas := templateReplicas("books", templates, true); replicas != 2 {
+		t.Errorf("expected 2 replicas for books, got %v", replicas)
+	}
+
+	// Templates of the same priority apply in name order, whatever the map order
+	templates["more"] = IndexTemplate{IndexPatterns: []string{"*"}, Priority: 0, Settings: map[string]interface{}{"number_of_replicas": 3}}
+	for i := 0; i < 20; i++ {
+		if replicas := templateReplicas("books", templates, false); replicas != 3 {
+			t.Fatalf("expected 3 replicas from the template named last, got %v", replicas)
+		}
+	}
+}
+
+func TestIndexManager_Maintenance(t *testing.T) {
+	ctx := context.Background()
+	es, mgr := newFakeCluster(t, "6.8.0")
+	if err := mgr.Create(ctx, "logs-1", CreateFlags{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	es.calls = nil
+	for _, op := range []func() error{
+		func() error { return mgr.Close(ctx, "logs-1") },
+		func() error { return mgr.Open(ctx, "logs-1") },
+		func() error { return mgr.Refresh(ctx, "logs-1") },
+		func() error { return mgr.Flush(ctx, "logs-1") },
+		func() error { return mgr.ForceMerge(ctx, "logs-1", 1) },
+		func() error { return mgr.ClearCache(ctx, "logs-1", ClearCacheFlags{Fielddata: true}) },
+		func() error { return mgr.Shrink(ctx, "logs-1", "logs-1-small", ShrinkFlags{}) },
+	} {
+		if err := op(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []string{
+		"POST /logs-1/_close",
+		"POST /logs-1/_open",
+		"POST /logs-1/_refresh",
+		"POST /logs-1/_flush",
+		"POST /logs-1/_forcemerge?max_num_segments=1",
+		"POST /logs-1/_cache/clear?fielddata=true",
+		"GET /logs-1/_settings?flat_settings=true",
+		"PUT /logs-1/_settings",
+		"POST /logs-1/_shrink/logs-1-small",
+		"PUT /logs-1/_settings",
+	}
+	if strings.Join(es.calls, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected calls\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(es.calls, "\n"))
+	}
+	if block, ok := es.indices["logs-1"].settings["blocks.write"]; ok {
+		t.Errorf("expected the write block of the source to be removed after the shrink, got %v", block)
+	}
+	es.indices["logs-1"].settings["blocks.write"] = true
+	if err := mgr.Shrink(ctx, "logs-1", "logs-1-tiny", ShrinkFlags{}); err != nil {
+		t.Fatal(err)
+	}
+	if block := fmt.Sprint(es.indices["logs-1"].settings["blocks.write"]); block != "true" {
+		t.Errorf("expected the source to stay read only, got %s", block)
+	}
+	if settings := es.indices["logs-1-small"].settings; fmt.Sprint(settings["number_of_shards"]) != "1" {
+		t.Errorf("expected the target of the shrink to have 1 shard, got %v", settings)
+	}
+
+	res, err := mgr.Rollover(ctx, "logs", RolloverFlags{MaxDocs: 10, MaxSize: "50gb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.RolledOver || res.OldIndex != "logs-1" || res.NewIndex != "logs-2" || !res.Conditions["[max_docs: 10]"] {
+		t.Errorf("unexpected rollover result %+v", res)
+	}
+
+	// Date math names are escaped in the path
+	es.calls = nil
+	if _, err := mgr.Rollover(ctx, "logs", RolloverFlags{NewIndex: "<logs-{now/d}-2>"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.ForceMerge(ctx, "<logs-{now/d}-2>", 0); err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{
+		"POST /logs/_rollover/%3Clogs-%7Bnow%2Fd%7D-2%3E",
+		"POST /%3Clogs-%7Bnow%2Fd%7D-2%3E/_forcemerge",
+	}
+	if strings.Join(es.calls, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected calls\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(es.calls, "\n"))
+	}
+}
+
+func TestIndexManager_MaintenanceVersions(t *testing.T) {
+	ctx := context.Background()
+
+	es, mgr := newFakeCluster(t, "2.0.0")
+	es.calls = nil
+	if err := mgr.ForceMerge(ctx, "logs-1", 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(es.calls) != 1 || es.calls[0] != "POST /logs-1/_optimize" {
+		t.Errorf("expected optimize before ES 2.1, got %v", es.calls)
+	}
+
+	err := mgr.Shrink(ctx, "logs-1", "logs-1-small", ShrinkFlags{})
+	if versionErr, ok := err.(*UnsupportedVersionError); !ok || versionErr.Required.String() != "5.0" {
+		t.Errorf("expected shrink to need ES 5.0, got %v", err)
+	}
+
+	_, mgr = newFakeCluster(t, "5.6.0")
+	if _, err := mgr.Rollover(ctx, "logs", RolloverFlags{MaxSize: "50gb"}); err == nil {
+		t.Error("expected rollover on size to need ES 6.1")
+	}
+}
@@ -0,0 +1,242 @@
+package esu
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+
+	"github.com/pkg/errors"
+	logger "github.com/sirupsen/logrus"
+	context "golang.org/x/net/context"
+)
+
+// ShrinkFlags are flags you can pass to IndexManager's Shrink method.
+type ShrinkFlags struct {
+	// Shards is the number of primary shards of the target, a factor of the
+	// shards of the source. Defaults to 1.
+	Shards int
+
+	// Settings and Aliases are given to the target index.
+	Settings map[string]interface{}
+	Aliases  []string
+
+	// WaitForActiveShards is how many shard copies of the target must be
+	// active before Shrink returns, a number or "all".
+	WaitForActiveShards string
+}
+
+// RolloverFlags are flags you can pass to IndexManager's Rollover method.
+// The alias is rolled over if any of the conditions is met, or always if none is set.
+type RolloverFlags struct {
+	// NewIndex names the new index. By default the number at the end of the
+	// old index name is incremented.
+	NewIndex string
+
+	MaxAge  string // like "7d"
+	MaxDocs int64
+	MaxSize string // like "50gb", ES >= 6.1
+
+	// DryRun checks the conditions without rolling over.
+	DryRun bool
+}
+
+// RolloverResult tells whether the alias was rolled over, and which conditions were met.
+type RolloverResult struct {
+	OldIndex   string          `json:"old_index"`
+	NewIndex   string          `json:"new_index"`
+	RolledOver bool            `json:"rolled_over"`
+	DryRun     bool            `json:"dry_run"`
+	Conditions map[string]bool `json:"conditions"`
+}
+
+// ClearCacheFlags select the caches ClearCache clears. All caches are cleared if none is set.
+type ClearCacheFlags struct {
+	Query     bool
+	Fielddata bool
+	Request   bool
+}
+
+// requireVersion returns an *UnsupportedVersionError if the cluster is older than major.minor
+func (mgr *indexManager) requireVersion(operation string, major, minor int) error {
+	if mgr.esVersion.AtLeast(major, minor) {
+		return nil
+	}
+	return &UnsupportedVersionError{Operation: operation, Version: mgr.esVersion, Required: ESVersion{major, minor}}
+}
+
+func (mgr *indexManager) Open(ctx context.Context, indexName string) error {
+	logger.Infof("Opening index %q", indexName)
+
+	resp, err := mgr.client.OpenIndex(indexName).Do(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to open index %q", indexName)
+	}
+	if !resp.Acknowledged {
+		return errors.Wrapf(ErrNotAcknowledged, "Unable to open index %q", indexName)
+	}
+	return nil
+}
+
+func (mgr *indexManager) Close(ctx context.Context, indexName string) error {
+	logger.Infof("Closing index %q", indexName)
+
+	resp, err := mgr.client.CloseIndex(indexName).Do(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to close index %q", indexName)
+	}
+	if !resp.Acknowledged {
+		return errors.Wrapf(ErrNotAcknowledged, "Unable to close index %q", indexName)
+	}
+	return nil
+}
+
+func (mgr *indexManager) Refresh(ctx context.Context, indexName string) error {
+	if _, err := mgr.client.Refresh(indexName).Do(ctx); err != nil {
+		return errors.Wrapf(err, "Unable to refresh index %q", indexName)
+	}
+	return nil
+}
+
+func (mgr *indexManager) Flush(ctx context.Context, indexName string) error {
+	if _, err := mgr.client.Flush(indexName).Do(ctx); err != nil {
+		return errors.Wrapf(err, "Unable to flush index %q", indexName)
+	}
+	return nil
+}
+
+func (mgr *indexManager) ForceMerge(ctx context.Context, indexName string, maxSegments int) error {
+	logger.Infof("Force merging index %q to %d segments", indexName, maxSegments)
+
+	// Before ES 2.1 force merge was called optimize
+	path := "/" + url.PathEscape(indexName) + "/_forcemerge"
+	if !mgr.esVersion.AtLeast(2, 1) {
+		path = "/" + url.PathEscape(indexName) + "/_optimize"
+	}
+	params := url.Values{}
+	if maxSegments > 0 {
+		params.Set("max_num_segments", strconv.Itoa(maxSegments))
+	}
+
+	if _, err := mgr.client.PerformRequest(ctx, "POST", path, params, nil); err != nil {
+		return errors.Wrapf(err, "Unable to force merge index %q", indexName)
+	}
+	return nil
+}
+
+func (mgr *indexManager) Shrink(ctx context.Context, source, target string, flags ShrinkFlags) (err error) {
+	if err := mgr.requireVersion("Shrink", 5, 0); err != nil {
+		return err
+	}
+
+	logger.Infof("Shrinking index %q into %q", source, target)
+
+	// The source must be read only while shrunk, and gets its write block
+	// back as it was once done, even if ctx is done
+	previous, err := mgr.GetSettings(ctx, source)
+	if err != nil {
+		return err
+	}
+	if err := mgr.PutSettings(ctx, source, map[string]interface{}{"blocks.write": true}); err != nil {
+		return err
+	}
+	defer func() {
+		restore := map[string]interface{}{"blocks.write": previous["blocks.write"]}
+		if rerr := mgr.PutSettings(context.Background(), source, restore); rerr != nil && err == nil {
+			err = rerr
+		}
+	}()
+
+	shards := flags.Shards
+	if shards <= 0 {
+		shards = 1
+	}
+	settings := jsonMap(flags.Settings).copy()
+	settings["number_of_shards"] = shards
+	// The target shouldn't inherit the block, nor the allocation the source
+	// was moved to a single node with
+	settings["blocks.write"] = nil
+	settings["routing.allocation.require._name"] = nil
+
+	body := jsonMap{"settings": jsonMap{"index": settings}}
+	if len(flags.Aliases) > 0 {
+		aliases := jsonMap{}
+		for _, alias := range flags.Aliases {
+			aliases[alias] = jsonMap{}
+		}
+		body["aliases"] = aliases
+	}
+
+	svc := mgr.client.ShrinkIndex(source, target).BodyJson(body)
+	if flags.WaitForActiveShards != "" {
+		svc = svc.WaitForActiveShards(flags.WaitForActiveShards)
+	}
+	resp, err := svc.Do(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to shrink index %q into %q", source, target)
+	}
+	if !resp.Acknowledged {
+		return errors.Wrapf(ErrNotAcknowledged, "Unable to shrink index %q into %q", source, target)
+	}
+	return nil
+}
+
+func (mgr *indexManager) Rollover(ctx context.Context, alias string, flags RolloverFlags) (*RolloverResult, error) {
+	if err := mgr.requireVersion("Rollover", 5, 0); err != nil {
+		return nil, err
+	}
+
+	conditions := jsonMap{}
+	if flags.MaxAge != "" {
+		conditions["max_age"] = flags.MaxAge
+	}
+	if flags.MaxDocs > 0 {
+		conditions["max_docs"] = flags.MaxDocs
+	}
+	if flags.MaxSize != "" {
+		if err := mgr.requireVersion("Rollover on index size", 6, 1); err != nil {
+			return nil, err
+		}
+		conditions["max_size"] = flags.MaxSize
+	}
+
+	path := "/" + url.PathEscape(alias) + "/_rollover"
+	if flags.NewIndex != "" {
+		path += "/" + url.PathEscape(flags.NewIndex)
+	}
+	params := url.Values{}
+	if flags.DryRun {
+		params.Set("dry_run", "true")
+	}
+
+	resp, err := mgr.client.PerformRequest(ctx, "POST", path, params, jsonMap{"conditions": conditions})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to roll over alias %q", alias)
+	}
+
+	var res RolloverResult
+	if err := json.Unmarshal(resp.Body, &res); err != nil {
+		return nil, errors.Wrap(err, "Invalid rollover response")
+	}
+	if res.RolledOver {
+		logger.Infof("Rolled alias %q over from %q to %q", alias, res.OldIndex, res.NewIndex)
+	}
+	return &res, nil
+}
+
+func (mgr *indexManager) ClearCache(ctx context.Context, indexName string, flags ClearCacheFlags) error {
+	params := url.Values{}
+	if flags.Query {
+		params.Set("query", "true")
+	}
+	if flags.Fielddata {
+		params.Set("fielddata", "true")
+	}
+	if flags.Request {
+		params.Set("request", "true")
+	}
+
+	if _, err := mgr.client.PerformRequest(ctx, "POST", "/"+url.PathEscape(indexName)+"/_cache/clear", params, nil); err != nil {
+		return errors.Wrapf(err, "Unable to clear caches of index %q", indexName)
+	}
+	return nil
+}
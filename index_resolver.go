@@ -0,0 +1,57 @@
+package esu
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TimestampIndex returns an index resolver that names the index after a
+// timestamp field of the document, e.g. TimestampIndex("events-", "@timestamp", "2006.01.02")
+// sends a document from 17 Oct 2026 to "events-2026.10.17". The field may be
+// dotted to reach into objects, and hold an RFC 3339 string or epoch milliseconds.
+func TimestampIndex(prefix, field, layout string) IndexResolver {
+	path := strings.Split(field, ".")
+
+	return func(data PumpData) (string, error) {
+		var doc map[string]interface{}
+		if err := json.Unmarshal([]byte(data.JSON), &doc); err != nil {
+			return "", errors.Wrap(err, "Invalid document JSON")
+		}
+
+		t, err := timestampField(doc, path)
+		if err != nil {
+			return "", errors.Wrapf(err, "Unable to read timestamp field %q", field)
+		}
+		return prefix + t.UTC().Format(layout), nil
+	}
+}
+
+// fieldValue returns the value at path in doc, or nil if there is none
+func fieldValue(doc map[string]interface{}, path []string) interface{} {
+	var v interface{} = doc
+	for _, key := range path {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = obj[key]
+	}
+	return v
+}
+
+func timestampField(doc map[string]interface{}, path []string) (time.Time, error) {
+	switch t := fieldValue(doc, path).(type) {
+	case string:
+		return time.Parse(time.RFC3339Nano, t)
+	case float64:
+		ms := int64(t)
+		return time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond)), nil
+	case nil:
+		return time.Time{}, errors.New("field not found")
+	default:
+		return time.Time{}, errors.Errorf("unsupported timestamp %v", t)
+	}
+}
@@ -5,6 +5,9 @@ import "github.com/mohae/deepcopy"
 type jsonMap map[string]interface{}
 
 func (m jsonMap) copy() jsonMap {
+	if m == nil {
+		return jsonMap{}
+	}
 	return deepcopy.Iface(m).(jsonMap)
 }
 
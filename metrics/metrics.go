@@ -0,0 +1,245 @@
+// Package metrics exports Prometheus metrics for esu datapumps and cluster health.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/leffen/esu"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixes every metric name
+const Namespace = "esu"
+
+// Metrics collects Datapump and cluster health metrics in its own registry
+type Metrics struct {
+	registry *prometheus.Registry
+
+	indexed     prometheus.Counter
+	failed      prometheus.Counter
+	retried     prometheus.Counter
+	commits     *prometheus.CounterVec
+	bulkLatency prometheus.Histogram
+
+	mu    sync.Mutex
+	pumps []*esu.Datapump
+}
+
+// New creates a metrics registry with the Datapump metrics registered
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		indexed: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: Namespace, Subsystem: "datapump", Name: "indexed_total",
+			Help: "Documents accepted by Elasticsearch, including those that succeeded on retry.",
+		}),
+		failed: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: Namespace, Subsystem: "datapump", Name: "failed_total",
+			Help: "Documents finally rejected by Elasticsearch.",
+		}),
+		retried: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: Namespace, Subsystem: "datapump", Name: "retried_total",
+			Help: "Documents resent by the retry policy.",
+		}),
+		commits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace, Subsystem: "datapump", Name: "bulk_requests_total",
+			Help: "Bulk requests sent, by outcome.",
+		}, []string{"outcome"}),
+		bulkLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: Namespace, Subsystem: "datapump", Name: "bulk_duration_seconds",
+			Help:    "Round trip time of bulk requests.",
+			Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.indexed, m.failed, m.retried, m.commits, m.bulkLatency,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: Namespace, Subsystem: "datapump", Name: "in_flight_requests",
+			Help: "Bulk requests sent but not yet answered.",
+		}, func() float64 {
+			return m.sumStats(func(s esu.PumpStats) int64 { return s.InFlight })
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: Namespace, Subsystem: "datapump", Name: "queued_documents",
+			Help: "Documents queued in bulk workers waiting to be committed.",
+		}, func() float64 {
+			return m.sumStats(func(s esu.PumpStats) int64 {
+				var queued int64
+				for _, w := range s.Bulk.Workers {
+					if w != nil {
+						queued += w.Queued
+					}
+				}
+				return queued
+			})
+		}),
+	)
+
+	return m
+}
+
+// Registry returns the registry the metrics are registered in, so more collectors can be added
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Handler returns an http.Handler serving the metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ServeHTTP serves the metrics, so Metrics can be mounted directly
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.Handler().ServeHTTP(w, r)
+}
+
+// Instrument records the bulk requests of pump. Any OnCommit hook
+// already set on pump is still called. The returned func stops reporting
+// the gauges of pump, and should be called once it is done.
+func (m *Metrics) Instrument(pump *esu.Datapump) (remove func()) {
+	next := pump.OnCommit
+	pump.OnCommit = func(commit esu.BulkCommit) {
+		m.observe(commit)
+		if next != nil {
+			next(commit)
+		}
+	}
+
+	m.mu.Lock()
+	m.pumps = append(m.pumps, pump)
+	m.mu.Unlock()
+
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for i, p := range m.pumps {
+			if p == pump {
+				m.pumps = append(m.pumps[:i], m.pumps[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (m *Metrics) observe(commit esu.BulkCommit) {
+	m.indexed.Add(float64(commit.Succeeded))
+	m.failed.Add(float64(commit.Failed))
+	m.retried.Add(float64(commit.Retried))
+	m.bulkLatency.Observe(commit.Duration.Seconds())
+
+	outcome := "success"
+	switch {
+	case commit.Err != nil:
+		outcome = "error"
+	case commit.Failed > 0:
+		outcome = "partial"
+	}
+	m.commits.WithLabelValues(outcome).Inc()
+}
+
+func (m *Metrics) sumStats(fn func(esu.PumpStats) int64) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var sum int64
+	for _, pump := range m.pumps {
+		sum += fn(pump.Stats())
+	}
+	return float64(sum)
+}
+
+// WatchCluster exports the health of the cluster cn is connected to. The
+// health is fetched on every scrape, waiting at most timeout.
+func (m *Metrics) WatchCluster(cn *esu.EsConnection, timeout time.Duration) error {
+	return m.registry.Register(newHealthCollector(cn, timeout))
+}
+
+// healthCollector fetches the cluster health when scraped
+type healthCollector struct {
+	cn      *esu.EsConnection
+	timeout time.Duration
+
+	up            *prometheus.Desc
+	status        *prometheus.Desc
+	nodes         *prometheus.Desc
+	dataNodes     *prometheus.Desc
+	activeShards  *prometheus.Desc
+	unassigned    *prometheus.Desc
+	relocating    *prometheus.Desc
+	initializing  *prometheus.Desc
+	pendingTasks  *prometheus.Desc
+	taskQueueWait *prometheus.Desc
+}
+
+func newHealthCollector(cn *esu.EsConnection, timeout time.Duration) *healthCollector {
+	desc := func(name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(Namespace, "cluster", name), help, labels, nil)
+	}
+
+	return &healthCollector{
+		cn:      cn,
+		timeout: timeout,
+
+		up:            desc("up", "Whether the last cluster health request succeeded."),
+		status:        desc("status", "Cluster health status, 1 for the current color.", "cluster", "color"),
+		nodes:         desc("nodes", "Number of nodes in the cluster.", "cluster"),
+		dataNodes:     desc("data_nodes", "Number of data nodes in the cluster.", "cluster"),
+		activeShards:  desc("active_shards", "Number of active shards.", "cluster"),
+		unassigned:    desc("unassigned_shards", "Number of unassigned shards.", "cluster"),
+		relocating:    desc("relocating_shards", "Number of relocating shards.", "cluster"),
+		initializing:  desc("initializing_shards", "Number of initializing shards.", "cluster"),
+		pendingTasks:  desc("pending_tasks", "Number of pending cluster tasks.", "cluster"),
+		taskQueueWait: desc("task_max_waiting_in_queue_seconds", "Longest time a task has waited in the queue.", "cluster"),
+	}
+}
+
+func (c *healthCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		c.up, c.status, c.nodes, c.dataNodes, c.activeShards, c.unassigned,
+		c.relocating, c.initializing, c.pendingTasks, c.taskQueueWait,
+	} {
+		ch <- d
+	}
+}
+
+func (c *healthCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx := context.Background()
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	res, err := c.cn.ClusterHealth(ctx)
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 1)
+
+	gauge := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v, res.ClusterName)
+	}
+
+	for _, color := range []string{"green", "yellow", "red"} {
+		var v float64
+		if res.Status == color {
+			v = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.status, prometheus.GaugeValue, v, res.ClusterName, color)
+	}
+	gauge(c.nodes, float64(res.NumberOfNodes))
+	gauge(c.dataNodes, float64(res.NumberOfDataNodes))
+	gauge(c.activeShards, float64(res.ActiveShards))
+	gauge(c.unassigned, float64(res.UnassignedShards))
+	gauge(c.relocating, float64(res.RelocatingShards))
+	gauge(c.initializing, float64(res.InitializingShards))
+	gauge(c.pendingTasks, float64(res.NumberOfPendingTasks))
+	gauge(c.taskQueueWait, float64(res.TaskMaxWaitTimeInQueueInMillis)/1000)
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leffen/esu"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"cluster_name":"test","status":"yellow","number_of_nodes":3,"unassigned_shards":2,"number_of_pending_tasks":1}`))
+	}))
+	defer srv.Close()
+
+	cn, err := esu.NewByUrl(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := New()
+	if err := m.WatchCluster(cn, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	pump := esu.NewDatapump(cn, "test", "doc", 10, 0, 1)
+	remove := m.Instrument(pump)
+	pump.OnCommit(esu.BulkCommit{Docs: 10, Succeeded: 8, Failed: 2, Retried: 3, Duration: 20 * time.Millisecond})
+
+	expected := `
+# HELP esu_cluster_status Cluster health status, 1 for the current color.
+# TYPE esu_cluster_status gauge
+esu_cluster_status{cluster="test",color="green"} 0
+esu_cluster_status{cluster="test",color="red"} 0
+esu_cluster_status{cluster="test",color="yellow"} 1
+# HELP esu_cluster_unassigned_shards Number of unassigned shards.
+# TYPE esu_cluster_unassigned_shards gauge
+esu_cluster_unassigned_shards{cluster="test"} 2
+# HELP esu_cluster_pending_tasks Number of pending cluster tasks.
+# TYPE esu_cluster_pending_tasks gauge
+esu_cluster_pending_tasks{cluster="test"} 1
+# HELP esu_datapump_indexed_total Documents accepted by Elasticsearch, including those that succeeded on retry.
+# TYPE esu_datapump_indexed_total counter
+esu_datapump_indexed_total 8
+# HELP esu_datapump_failed_total Documents finally rejected by Elasticsearch.
+# TYPE esu_datapump_failed_total counter
+esu_datapump_failed_total 2
+# HELP esu_datapump_bulk_requests_total Bulk requests sent, by outcome.
+# TYPE esu_datapump_bulk_requests_total counter
+esu_datapump_bulk_requests_total{outcome="partial"} 1
+`
+	err = testutil.GatherAndCompare(m.Registry(), strings.NewReader(expected),
+		"esu_cluster_status", "esu_cluster_unassigned_shards", "esu_cluster_pending_tasks",
+		"esu_datapump_indexed_total", "esu_datapump_failed_total", "esu_datapump_bulk_requests_total")
+	if err != nil {
+		t.Error(err)
+	}
+
+	remove()
+	remove()
+	if len(m.pumps) != 0 {
+		t.Errorf("expected the pump to be removed, got %d pumps", len(m.pumps))
+	}
+}
@@ -0,0 +1,180 @@
+package esu
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxLineSize is the longest document line the readers accept
+const maxLineSize = 64 * 1024 * 1024
+
+// MalformedLine is a line of input that couldn't be turned into a record
+type MalformedLine struct {
+	Line int
+	Text string
+	Err  error
+}
+
+func (e *MalformedLine) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// NDJSONReader turns newline delimited JSON documents into PumpData records.
+// Gzip compressed input is detected and decompressed.
+type NDJSONReader struct {
+	// IDField is the dotted path of the field holding the document id.
+	// If empty, Elasticsearch generates the ids.
+	IDField string
+
+	// Hits reads lines in the ExportHit format written by Exporter, taking
+	// the id, routing and parent from the metadata unless IDField is set.
+	Hits bool
+
+	// OnMalformed, if set, is called for every line that isn't a JSON object
+	// or lacks the id field. Such lines are skipped, and logged if not set.
+	OnMalformed func(line *MalformedLine)
+
+	// Lines and Malformed count the lines read and skipped
+	Lines     int
+	Malformed int
+
+	r *bufio.Reader
+}
+
+// NewNDJSONReader creates a reader of the documents in r
+func NewNDJSONReader(r io.Reader) (*NDJSONReader, error) {
+	br, err := maybeGunzip(r)
+	if err != nil {
+		return nil, err
+	}
+	return &NDJSONReader{r: br}, nil
+}
+
+// maybeGunzip decompresses r if it starts with the gzip magic number
+func maybeGunzip(r io.Reader) (*bufio.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "Unable to read input")
+	}
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, errors.Wrap(err, "Invalid gzip input")
+		}
+		return bufio.NewReader(gz), nil
+	}
+	return br, nil
+}
+
+// Pump sends a record for every document on lc until the input is exhausted
+// or ctx is done. It does not send an EOF record.
+func (nr *NDJSONReader) Pump(ctx context.Context, lc chan<- PumpData) error {
+	scanner := bufio.NewScanner(nr.r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	for scanner.Scan() {
+		nr.Lines++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		data, err := nr.record(line)
+		if err != nil {
+			nr.malformed(&MalformedLine{Line: nr.Lines, Text: string(line), Err: err})
+			continue
+		}
+
+		select {
+		case lc <- data:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return errors.Wrap(scanner.Err(), "Unable to read input")
+}
+
+func (nr *NDJSONReader) record(line []byte) (PumpData, error) {
+	if nr.Hits {
+		return nr.hit(line)
+	}
+	return nr.document(line)
+}
+
+// document turns a line holding a plain document into a record
+func (nr *NDJSONReader) document(line []byte) (PumpData, error) {
+	if nr.IDField == "" {
+		if len(line) == 0 || line[0] != '{' || !json.Valid(line) {
+			return PumpData{}, errors.New("not a JSON object")
+		}
+		return PumpData{JSON: string(line)}, nil
+	}
+
+	var doc map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(line))
+	d.UseNumber()
+	if err := d.Decode(&doc); err != nil {
+		return PumpData{}, errors.Wrap(err, "not a JSON object")
+	}
+
+	id, err := fieldString(doc, nr.IDField)
+	if err != nil {
+		return PumpData{}, err
+	}
+	return PumpData{UID: id, JSON: string(line)}, nil
+}
+
+// hit turns a line in the ExportHit format into a record
+func (nr *NDJSONReader) hit(line []byte) (PumpData, error) {
+	var hit ExportHit
+	if err := json.Unmarshal(line, &hit); err != nil {
+		return PumpData{}, errors.Wrap(err, "not a JSON object")
+	}
+	if len(hit.Source) == 0 || hit.Source[0] != '{' {
+		return PumpData{}, errors.New("missing _source")
+	}
+
+	data, err := nr.document(hit.Source)
+	if err != nil {
+		return PumpData{}, err
+	}
+	if nr.IDField == "" {
+		data.UID = hit.ID
+	}
+	data.Routing = hit.Routing
+	data.Parent = hit.Parent
+	return data, nil
+}
+
+func (nr *NDJSONReader) malformed(line *MalformedLine) {
+	nr.Malformed++
+	if nr.OnMalformed != nil {
+		nr.OnMalformed(line)
+		return
+	}
+	log.Warnf("Skipping malformed %s", line)
+}
+
+// fieldString returns the scalar value at the dotted path in doc as a string
+func fieldString(doc map[string]interface{}, path string) (string, error) {
+	switch s := fieldValue(doc, strings.Split(path, ".")).(type) {
+	case string:
+		return s, nil
+	case json.Number, bool:
+		return fmt.Sprint(s), nil
+	case nil:
+		return "", errors.Errorf("missing field %q", path)
+	default:
+		return "", errors.Errorf("field %q is not a scalar", path)
+	}
+}
@@ -0,0 +1,54 @@
+package esu
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNDJSONReader(t *testing.T) {
+	input := strings.Join([]string{
+		`{"id":1,"name":"first"}`,
+		`not json`,
+		``,
+		`{"meta":{"id":"x"}}`,
+		`{"id":"3","name":"third"}`,
+	}, "\n")
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(input))
+	w.Close()
+
+	for name, r := range map[string]*bytes.Reader{"plain": bytes.NewReader([]byte(input)), "gzip": bytes.NewReader(gz.Bytes())} {
+		nr, err := NewNDJSONReader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nr.IDField = "id"
+
+		var malformed []int
+		nr.OnMalformed = func(line *MalformedLine) {
+			malformed = append(malformed, line.Line)
+		}
+
+		lc := make(chan PumpData, 10)
+		if err := nr.Pump(context.Background(), lc); err != nil {
+			t.Fatal(err)
+		}
+		close(lc)
+
+		var ids []string
+		for data := range lc {
+			ids = append(ids, data.UID)
+		}
+		if strings.Join(ids, ",") != "1,3" {
+			t.Errorf("%s: expected ids 1,3, got %v", name, ids)
+		}
+		if nr.Lines != 5 || nr.Malformed != 2 || len(malformed) != 2 || malformed[0] != 2 || malformed[1] != 4 {
+			t.Errorf("%s: expected lines 2 and 4 to be malformed, got %v of %d lines", name, malformed, nr.Lines)
+		}
+	}
+}
@@ -2,21 +2,16 @@ package esu
 
 import (
 	"context"
-	"fmt"
-)
 
-func (cn *EsConnection) Ping() {
-	ctxb := context.Background()
-	uri := cn.URL.String()
-	res, _, err := cn.Client.Ping(uri).Do(ctxb)
+	"github.com/pkg/errors"
+	elastic "gopkg.in/olivere/elastic.v5"
+)
 
+// Ping checks that the node the connection points to is reachable
+func (cn *EsConnection) Ping(ctx context.Context) (*elastic.PingResult, error) {
+	res, _, err := cn.Client.Ping(cn.URL.String()).Do(ctx)
 	if err != nil {
-		exitWithError(err)
+		return nil, errors.Wrapf(err, "Ping of %s failed", cn.URL)
 	}
-
-	t := NewTable("Cluster", res.ClusterName)
-	t.Add("Node", fmt.Sprintf("%s [%v]", res.Name, uri))
-	t.Add("Tag Line", res.TagLine)
-	t.Add("ES Version", res.Version.Number)
-	t.Print()
+	return res, nil
 }
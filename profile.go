@@ -0,0 +1,216 @@
+package esu
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// DefaultProfilesFile is where LoadDefaultProfiles looks when ESU_CONFIG isn't set
+const DefaultProfilesFile = "~/.esu.yml"
+
+// Profile describes how to connect to a cluster
+type Profile struct {
+	URLs        []string          `yaml:"urls"`
+	Sniff       bool              `yaml:"sniff"`
+	Healthcheck bool              `yaml:"healthcheck"`
+	Username    string            `yaml:"username"`
+	Password    string            `yaml:"password"`
+	APIKey      string            `yaml:"api_key"`
+	Headers     map[string]string `yaml:"headers"`
+	CACert      string            `yaml:"ca_cert"`
+	ClientCert  string            `yaml:"client_cert"`
+	ClientKey   string            `yaml:"client_key"`
+	Insecure    bool              `yaml:"insecure"`
+	Timeout     string            `yaml:"timeout"`
+}
+
+// Profiles is a set of named connection profiles, read from a file like
+//
+//	default: dev
+//	profiles:
+//	  dev:
+//	    urls: ["http://localhost:9200"]
+//	  prod:
+//	    urls: ["https://es1.example.com:9200", "https://es2.example.com:9200"]
+//	    username: importer
+//	    password: ${ES_PROD_PASSWORD}
+//	    ca_cert: /etc/ssl/es-ca.pem
+//	    timeout: 30s
+type Profiles struct {
+	Default  string             `yaml:"default"`
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// envRef matches a ${VAR} reference, or one escaped as $${VAR}
+var envRef = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// LoadProfiles reads connection profiles from the YAML file at path.
+// Environment variables referenced as ${VAR} in string values are expanded,
+// $${VAR} is kept as the literal ${VAR}. Any other $ is left as is.
+func LoadProfiles(path string) (*Profiles, error) {
+	data, err := os.ReadFile(expandHome(path))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read profiles file %q", path)
+	}
+
+	var profiles Profiles
+	if err := yaml.Unmarshal(data, &profiles); err != nil {
+		return nil, errors.Wrapf(err, "Invalid profiles file %q", path)
+	}
+	for name, profile := range profiles.Profiles {
+		profiles.Profiles[name] = profile.expandEnv()
+	}
+	return &profiles, nil
+}
+
+// LoadDefaultProfiles reads the profiles file named by ESU_CONFIG, or DefaultProfilesFile.
+// A missing default file gives an empty set of profiles.
+func LoadDefaultProfiles() (*Profiles, error) {
+	path := EnvGetWithDefault("ESU_CONFIG", "")
+	if path == "" {
+		if _, err := os.Stat(expandHome(DefaultProfilesFile)); os.IsNotExist(err) {
+			return &Profiles{}, nil
+		}
+		path = DefaultProfilesFile
+	}
+	return LoadProfiles(path)
+}
+
+// Names returns the sorted names of the profiles
+func (p *Profiles) Names() []string {
+	names := make([]string, 0, len(p.Profiles))
+	for name := range p.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the named profile. An empty name picks the profile named by
+// ES_PROFILE, or else the default one.
+func (p *Profiles) Get(name string) (Profile, error) {
+	if name == "" {
+		name = EnvGetWithDefault("ES_PROFILE", p.Default)
+	}
+	if name == "" {
+		return Profile{}, nil
+	}
+
+	profile, ok := p.Profiles[name]
+	if !ok {
+		return Profile{}, errors.Errorf("Unknown connection profile %q", name)
+	}
+	return profile, nil
+}
+
+// Connect creates a ES connection object from the named profile. The ES_*
+// variables read by FromEnv override the profile, and opts override both.
+func (p *Profiles) Connect(name string, opts ...Option) (*EsConnection, error) {
+	profile, err := p.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	profileOpts, err := profile.Options()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid connection profile %q", name)
+	}
+
+	all := append(profileOpts, FromEnv())
+	return Connect(append(all, opts...)...)
+}
+
+// ConnectProfile creates a ES connection object from a profile in the default profiles file
+func ConnectProfile(name string, opts ...Option) (*EsConnection, error) {
+	profiles, err := LoadDefaultProfiles()
+	if err != nil {
+		return nil, err
+	}
+	return profiles.Connect(name, opts...)
+}
+
+// Options returns the connection options the profile describes
+func (profile Profile) Options() ([]Option, error) {
+	var opts []Option
+
+	if len(profile.URLs) > 0 {
+		opts = append(opts, WithURLs(profile.URLs...))
+	}
+	opts = append(opts, WithSettings(ConnectionSettings{Sniff: profile.Sniff, Healthcheck: profile.Healthcheck}))
+
+	if profile.Username != "" {
+		opts = append(opts, WithBasicAuth(profile.Username, profile.Password))
+	}
+	if profile.APIKey != "" {
+		opts = append(opts, WithAPIKey(profile.APIKey))
+	}
+	for name, value := range profile.Headers {
+		opts = append(opts, WithHeader(name, value))
+	}
+	if profile.CACert != "" {
+		opts = append(opts, WithCACert(expandHome(profile.CACert)))
+	}
+	if profile.ClientCert != "" {
+		opts = append(opts, WithClientCert(expandHome(profile.ClientCert), expandHome(profile.ClientKey)))
+	}
+	if profile.Insecure {
+		opts = append(opts, WithInsecureSkipVerify())
+	}
+	if profile.Timeout != "" {
+		timeout, err := time.ParseDuration(profile.Timeout)
+		if err != nil {
+			return nil, errors.Wrap(err, "Invalid timeout")
+		}
+		opts = append(opts, WithTimeout(timeout))
+	}
+
+	return opts, nil
+}
+
+// expandEnv returns the profile with the environment references in its string values expanded
+func (profile Profile) expandEnv() Profile {
+	urls := make([]string, len(profile.URLs))
+	for i, u := range profile.URLs {
+		urls[i] = expandEnv(u)
+	}
+	profile.URLs = urls
+
+	headers := make(map[string]string, len(profile.Headers))
+	for name, value := range profile.Headers {
+		headers[name] = expandEnv(value)
+	}
+	profile.Headers = headers
+
+	for _, field := range []*string{&profile.Username, &profile.Password, &profile.APIKey,
+		&profile.CACert, &profile.ClientCert, &profile.ClientKey, &profile.Timeout} {
+		*field = expandEnv(*field)
+	}
+	return profile
+}
+
+// expandEnv replaces the ${VAR} references in s with the value of the variable
+func expandEnv(s string) string {
+	return envRef.ReplaceAllStringFunc(s, func(ref string) string {
+		if ref[1] == '$' {
+			return ref[1:]
+		}
+		return os.Getenv(envRef.FindStringSubmatch(ref)[1])
+	})
+}
+
+func expandHome(path string) string {
+	if len(path) < 2 || path[:2] != "~/" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
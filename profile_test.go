@@ -0,0 +1,103 @@
+package esu
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	var user string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _, _ = r.BasicAuth()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"node-1","cluster_name":"test","version":{"number":"5.5.2"}}`))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "esu.yml")
+	config := `
+default: dev
+profiles:
+  dev:
+    urls: ["http://127.0.0.1:1"]
+  staging:
+    urls: ["` + srv.URL + `"]
+    username: staging
+    password: ${TEST_ES_PASSWORD}
+    timeout: 5s
+`
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_ES_PASSWORD", "secret")
+
+	profiles, err := LoadProfiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := profiles.Names(); len(names) != 2 || names[0] != "dev" {
+		t.Errorf("unexpected profiles %v", names)
+	}
+
+	staging, err := profiles.Get("staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if staging.Password != "secret" {
+		t.Errorf("expected password from the environment, got %q", staging.Password)
+	}
+
+	t.Setenv("ES_PROFILE", "staging")
+	connection, err := profiles.Connect("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := connection.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if user != "staging" {
+		t.Errorf("expected the staging credentials, got user %q", user)
+	}
+
+	if _, err := profiles.Connect("prod"); err == nil {
+		t.Error("expected an error for an unknown profile")
+	}
+}
+
+func TestLoadProfiles_LiteralDollar(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "esu.yml")
+	config := `
+profiles:
+  prod:
+    urls: ["https://${TEST_ES_HOST}:9200"]
+    password: pa$word$$1
+    api_key: $${TEST_ES_HOST}
+`
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_ES_HOST", "es1")
+	t.Setenv("word", "oops")
+
+	profiles, err := LoadProfiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prod, err := profiles.Get("prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prod.Password != "pa$word$$1" {
+		t.Errorf("expected the literal password, got %q", prod.Password)
+	}
+	if prod.APIKey != "${TEST_ES_HOST}" {
+		t.Errorf("expected the escaped reference to be kept, got %q", prod.APIKey)
+	}
+	if len(prod.URLs) != 1 || prod.URLs[0] != "https://es1:9200" {
+		t.Errorf("expected the url to be expanded, got %v", prod.URLs)
+	}
+}
@@ -0,0 +1,65 @@
+package esu_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/leffen/esu"
+	"github.com/leffen/esu/mocks"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+)
+
+var _ esu.IndexManager = &mocks.IndexManager{}
+
+func TestRebuild_Mock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mgr := &mocks.IndexManager{}
+	permanent := esu.PermanentFlags{WaitForStatus: "green"}
+
+	create := mgr.On("Create", ctx, "books_v2", esu.CreateFlags{Temporary: true}, nil).Return(nil)
+	makePermanent := mgr.On("MakePermanent", ctx, "books_v2", permanent).Return(nil).NotBefore(create)
+	swap := mgr.On("SwapAlias", ctx, "books", "books_v2").Return([]string{"books_v1"}, nil).NotBefore(makePermanent)
+	mgr.On("Delete", ctx, "books_v1").Return(nil).NotBefore(swap)
+
+	err := esu.Rebuild(ctx, mgr, "books", "books_v2", nil, func(indexName string) error {
+		return nil
+	}, esu.RebuildFlags{DeleteOld: true, Permanent: permanent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr.AssertExpectations(t)
+}
+
+func TestRebuild_MockFillFails(t *testing.T) {
+	mgr := &mocks.IndexManager{}
+	mgr.On("Create", mock.Anything, "books_v2", mock.Anything, nil).Return(nil)
+	mgr.On("Delete", mock.Anything, "books_v2").Return(nil)
+
+	err := esu.Rebuild(context.Background(), mgr, "books", "books_v2", nil, func(indexName string) error {
+		return errors.New("boom")
+	}, esu.RebuildFlags{})
+	if err == nil {
+		t.Fatal("expected the fill error")
+	}
+	mgr.AssertExpectations(t)
+	mgr.AssertNotCalled(t, "SwapAlias", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRebuild_MockSwapFails(t *testing.T) {
+	mgr := &mocks.IndexManager{}
+	mgr.On("Create", mock.Anything, "books_v2", mock.Anything, nil).Return(nil)
+	mgr.On("MakePermanent", mock.Anything, "books_v2", mock.Anything).Return(nil)
+	swap := mgr.On("SwapAlias", mock.Anything, "books", "books_v2").Return(nil, errors.New("boom"))
+	mgr.On("Delete", mock.Anything, "books_v2").Return(nil).NotBefore(swap)
+
+	err := esu.Rebuild(context.Background(), mgr, "books", "books_v2", nil, func(indexName string) error {
+		return nil
+	}, esu.RebuildFlags{DeleteOld: true})
+	if err == nil || !strings.Contains(err.Error(), "books_v2") {
+		t.Fatalf("expected the swap error naming books_v2, got %v", err)
+	}
+	mgr.AssertExpectations(t)
+}
@@ -0,0 +1,178 @@
+package esu
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+// Transform changes a document on its way from the source to the target
+// index. It returns ErrSkipDocument to leave the document out.
+type Transform func(data PumpData) (PumpData, error)
+
+// Reindex defaults
+const (
+	DefaultReindexBulkActions = 1000
+	DefaultReindexBulkSize    = 5 << 20
+	DefaultReindexBulkWorkers = 2
+)
+
+// ReindexOptions controls Reindex. The zero value copies every document
+// with its source type and the default bulk sizes.
+type ReindexOptions struct {
+	// Query selects the documents to copy, all documents if nil
+	Query elastic.Query
+
+	// Slices and PageSize control the scroll over the source, see Exporter
+	Slices   int
+	PageSize int
+
+	// Type is the document type in the target index, the type of each
+	// source document if empty. It is set before Transform is applied.
+	Type string
+
+	// BulkActions, BulkSize and BulkWorkers tune the Datapump writing to
+	// the target, see NewDatapump
+	BulkActions int
+	BulkSize    int
+	BulkWorkers int
+
+	// Transform, if set, is applied to every document before it is indexed
+	Transform Transform
+
+	// Configure, if set, is called with the Datapump writing to the target
+	// before it runs, to set retries or a failure sink
+	Configure func(pump *Datapump)
+
+	// SkipCountCheck skips comparing the document counts once done
+	SkipCountCheck bool
+}
+
+// ReindexResult summarizes a Reindex
+type ReindexResult struct {
+	Exported int64 // documents read from the source
+	Skipped  int64 // documents left out by the transform
+	Source   int64 // documents in the source matching the query once done
+	Target   int64 // documents in the target index once done
+	Stats    PumpStats
+}
+
+// Reindex copies the documents of srcIndex on src to dstIndex on dst, scrolling
+// the source and pumping into the target through a Datapump. Unless
+// opts.SkipCountCheck is set, the target is refreshed and a *CountMismatchError
+// is returned if it doesn't hold as many documents as copied from the source,
+// so the target should be empty to begin with.
+func Reindex(ctx context.Context, src, dst *EsConnection, srcIndex, dstIndex string, opts ReindexOptions) (*ReindexResult, error) {
+	ex := NewExporter(src, srcIndex)
+	ex.Query = opts.Query
+	if opts.Slices > 0 {
+		ex.Slices = opts.Slices
+	}
+	if opts.PageSize > 0 {
+		ex.PageSize = opts.PageSize
+	}
+
+	pump := NewDatapump(dst, dstIndex, opts.Type, DefaultReindexBulkActions, DefaultReindexBulkSize, DefaultReindexBulkWorkers)
+	if opts.BulkActions > 0 {
+		pump.BulkActions = opts.BulkActions
+	}
+	if opts.BulkSize > 0 {
+		pump.BulkSize = opts.BulkSize
+	}
+	if opts.BulkWorkers > 0 {
+		pump.BulkWorkers = opts.BulkWorkers
+	}
+	if opts.Configure != nil {
+		opts.Configure(pump)
+	}
+
+	res := &ReindexResult{}
+
+	// The scroll is stopped if the pump gives up early
+	readCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lc := make(chan PumpData)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(lc)
+		readErr <- pumpTransformed(readCtx, ex, opts, lc, &res.Skipped)
+	}()
+
+	runErr := pump.Run(ctx, lc)
+	cancel()
+	err := <-readErr
+	res.Exported = ex.Exported()
+	res.Stats = pump.Stats()
+
+	if runErr != nil {
+		return res, runErr
+	}
+	if err != nil {
+		return res, err
+	}
+	if opts.SkipCountCheck {
+		return res, nil
+	}
+
+	if _, err := dst.Client.Refresh(dstIndex).Do(ctx); err != nil {
+		return res, errors.Wrapf(err, "Unable to refresh index %s", dstIndex)
+	}
+
+	count := src.Client.Count(srcIndex)
+	if opts.Query != nil {
+		count = count.Query(opts.Query)
+	}
+	if res.Source, err = count.Do(ctx); err != nil {
+		return res, errors.Wrapf(err, "Unable to count documents in %s", srcIndex)
+	}
+	if res.Target, err = dst.Client.Count(dstIndex).Do(ctx); err != nil {
+		return res, errors.Wrapf(err, "Unable to count documents in %s", dstIndex)
+	}
+
+	if res.Target != res.Source-res.Skipped {
+		return res, &CountMismatchError{Source: res.Source, Skipped: res.Skipped, Target: res.Target}
+	}
+	return res, nil
+}
+
+// pumpTransformed sends the documents of ex on lc, setting their type and
+// applying the transform of opts. Documents left out are counted in skipped.
+func pumpTransformed(ctx context.Context, ex *Exporter, opts ReindexOptions, lc chan<- PumpData, skipped *int64) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	hits := make(chan PumpData)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(hits)
+		readErr <- ex.Pump(ctx, hits)
+	}()
+
+	for data := range hits {
+		if opts.Type != "" {
+			data.Type = opts.Type
+		}
+		if opts.Transform != nil {
+			transformed, err := opts.Transform(data)
+			if errors.Cause(err) == ErrSkipDocument {
+				atomic.AddInt64(skipped, 1)
+				continue
+			}
+			if err != nil {
+				cancel()
+				<-readErr
+				return errors.Wrapf(err, "Unable to transform document %s", data.UID)
+			}
+			data = transformed
+		}
+
+		select {
+		case lc <- data:
+		case <-ctx.Done():
+		}
+	}
+	return <-readErr
+}
@@ -0,0 +1,74 @@
+package esu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestReindex(t *testing.T) {
+	src, scn := newFakeES(t)
+	for i := 0; i < 20; i++ {
+		id := fmt.Sprint(i)
+		src.docs[id] = fmt.Sprintf(`{"n":%d}`, i)
+		src.indices[id] = "old"
+	}
+	src.types["7"] = "legacy"
+	dst, dcn := newFakeES(t)
+
+	opts := ReindexOptions{
+		Slices:   2,
+		PageSize: 3,
+		Transform: func(data PumpData) (PumpData, error) {
+			var doc map[string]int
+			if err := json.Unmarshal([]byte(data.JSON), &doc); err != nil {
+				return data, err
+			}
+			if doc["n"]%5 == 0 {
+				return data, ErrSkipDocument
+			}
+			data.JSON = fmt.Sprintf(`{"n":%d,"copied":true}`, doc["n"])
+			return data, nil
+		},
+		BulkActions: 4,
+	}
+
+	res, err := Reindex(context.Background(), scn, dcn, "old", "new", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Exported != 20 || res.Skipped != 4 || res.Source != 20 || res.Target != 16 {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if dst.docs["7"] != `{"n":7,"copied":true}` || dst.indices["7"] != "new" {
+		t.Errorf("expected transformed document 7 in new, got %s in %s", dst.docs["7"], dst.indices["7"])
+	}
+	if _, ok := dst.docs["5"]; ok {
+		t.Error("expected document 5 to be skipped")
+	}
+	if dst.types["7"] != "legacy" || dst.types["8"] != "doc" {
+		t.Errorf("expected the source types to be kept, got %q and %q", dst.types["7"], dst.types["8"])
+	}
+	if dst.bulks < 4 {
+		t.Errorf("expected bulk requests of 4 documents, got %d requests", dst.bulks)
+	}
+
+	// Documents already in the target make the counts differ
+	dst.docs["extra"] = `{}`
+	dst.indices["extra"] = "new"
+	_, err = Reindex(context.Background(), scn, dcn, "old", "new", opts)
+	if mismatch, ok := err.(*CountMismatchError); !ok || mismatch.Target != 17 {
+		t.Errorf("expected a count mismatch, got %v", err)
+	}
+
+	// The type can be set for every document
+	opts.Type = "item"
+	opts.SkipCountCheck = true
+	if _, err := Reindex(context.Background(), scn, dcn, "old", "new", opts); err != nil {
+		t.Fatal(err)
+	}
+	if dst.types["7"] != "item" || dst.types["8"] != "item" {
+		t.Errorf("expected type item, got %q and %q", dst.types["7"], dst.types["8"])
+	}
+}
@@ -0,0 +1,208 @@
+package esu
+
+import (
+	"fmt"
+
+	elastic "gopkg.in/olivere/elastic.v5"
+
+	"github.com/fatih/color"
+)
+
+// PingTable renders a ping response from the node at uri as a table
+func PingTable(uri string, res *elastic.PingResult) *Table {
+	t := NewTable("Cluster", res.ClusterName)
+	t.Add("Node", fmt.Sprintf("%s [%v]", res.Name, uri))
+	t.Add("Tag Line", res.TagLine)
+	t.Add("ES Version", res.Version.Number)
+	return t
+}
+
+// ClusterHealthTable renders a cluster health response as a table
+func ClusterHealthTable(res *elastic.ClusterHealthResponse) *Table {
+	c := color.New()
+	switch res.Status {
+	case "red":
+		c.Add(color.FgRed)
+	case "green":
+		c.Add(color.FgGreen)
+	default:
+		c.Add(color.FgYellow)
+	}
+
+	t := NewTable(res.ClusterName, fmt.Sprint("status: ", res.Status))
+	t.HeaderColor = c.Add(color.Underline)
+
+	// Node Info
+	t.Add("Total Nodes", res.NumberOfNodes)
+	t.Add("Data Nodes", res.NumberOfDataNodes)
+	t.Add()
+
+	// Shard Info
+	t.Add("Active Shards", fmt.Sprintf("%d (%.2f%%)", res.ActiveShards, res.ActiveShardsPercentAsNumber))
+	if res.ActivePrimaryShards > 0 {
+		t.Add("Primary Shards", res.ActivePrimaryShards)
+	}
+	if res.RelocatingShards > 0 {
+		t.Add("Relocating Shards", res.RelocatingShards)
+	}
+	if res.InitializingShards > 0 {
+		t.Add("Initializing Shards", res.InitializingShards)
+	}
+	if res.UnassignedShards > 0 || res.DelayedUnassignedShards > 0 {
+		t.Add("Unassigned Shards", fmt.Sprintf("%d (%d Delayed)", res.UnassignedShards, res.DelayedUnassignedShards))
+	}
+	t.Add()
+
+	// Task Info
+	t.Add("Pending Tasks", res.NumberOfPendingTasks)
+	t.Add("Max Time in Task Queue", fmt.Sprintf("%d ms", res.TaskMaxWaitTimeInQueueInMillis))
+	t.Add("In-Flight Fetches", res.NumberOfInFlightFetch)
+
+	return t
+}
+
+// ClusterStatsTables renders a cluster stats response as a set of tables, one per section
+func ClusterStatsTables(res *elastic.ClusterStatsResponse) []*Table {
+	var tables []*Table
+
+	var t *Table
+	if res.Indices != nil && res.Indices.Shards != nil {
+		t = NewTable("Indices", "")
+		t.Add("Count", res.Indices.Count)
+		t.Add("Shards", fmt.Sprintf("%d (%d Primaries)", res.Indices.Shards.Total, res.Indices.Shards.Primaries))
+		t.Add("Replication Ratio", res.Indices.Shards.Replication)
+		tables = append(tables, t)
+	}
+
+	if res.Indices != nil {
+		t = NewTable("Storage", "")
+		if res.Indices.Docs != nil {
+			t.Add("Total Documents", fmt.Sprintf("%d (%d Deleted)", res.Indices.Docs.Count, res.Indices.Docs.Deleted))
+			t.Add()
+		}
+
+		if res.Indices.Store != nil {
+			t.Add("Store Size", res.Indices.Store.Size)
+			t.Add("Store Throttle", res.Indices.Store.ThrottleTime)
+			t.Add()
+		}
+
+		if res.Indices.FieldData != nil {
+			t.Add("Field Data Size", res.Indices.FieldData.MemorySize)
+			t.Add("Field Data Evictions", res.Indices.FieldData.Evictions)
+			t.Add()
+		}
+
+		if res.Indices.FilterCache != nil {
+			t.Add("Filter Cache Size", res.Indices.FilterCache.MemorySize)
+			t.Add("Filter Cache Evictions", res.Indices.FilterCache.Evictions)
+			t.Add()
+		}
+
+		if res.Indices.IdCache != nil {
+			t.Add("ID Cache Size", res.Indices.IdCache.MemorySize)
+		}
+
+		if res.Indices.Completion != nil {
+			t.Add("Completion Size", res.Indices.Completion.Size)
+		}
+
+		tables = append(tables, t)
+	}
+
+	if res.Indices != nil && res.Indices.Percolate != nil {
+		t = NewTable("Percolation", "")
+		t.Add("Total", res.Indices.Percolate.Total)
+		t.Add("Current", res.Indices.Percolate.Current)
+		t.Add("Queries", res.Indices.Percolate.Queries)
+		if res.Indices.Percolate.Time != "" {
+			t.Add("Get Time", res.Indices.Percolate.Time)
+		}
+		if res.Indices.Percolate.MemorySizeInBytes > 0 {
+			t.Add("Size", res.Indices.Percolate.MemorySize)
+		}
+		tables = append(tables, t)
+	}
+
+	if res.Indices != nil && res.Indices.Segments != nil {
+		t = NewTable("Segments", "")
+		t.Add("Count", res.Indices.Segments.Count)
+		t.Add("Size", res.Indices.Segments.Memory)
+		t.Add()
+
+		t.Add("Index Writer Size", fmt.Sprintf("%s (%s Max)", res.Indices.Segments.IndexWriterMemory, res.Indices.Segments.IndexWriterMaxMemory))
+		t.Add("Version Map Size", res.Indices.Segments.VersionMapMemory)
+		t.Add("Fixed Bit Set Size", res.Indices.Segments.FixedBitSet)
+		tables = append(tables, t)
+	}
+
+	if res.Nodes != nil {
+		t = NewTable("Nodes", "")
+		if res.Nodes.Count != nil {
+			t.Add("Count", res.Nodes.Count.Total)
+			t.Add("Data", res.Nodes.Count.Data)
+			t.Add("CoordinatingOnly", res.Nodes.Count.CoordinatingOnly)
+			t.Add("Master", res.Nodes.Count.Master)
+			t.Add("Ingest", res.Nodes.Count.Ingest)
+			t.Add()
+		}
+
+		if res.Nodes.OS != nil {
+			t.Add("Processors", res.Nodes.OS.AvailableProcessors)
+		}
+		if res.Nodes.Process != nil {
+			if res.Nodes.Process.CPU != nil {
+				t.Add("CPU Usage", fmt.Sprintf("%.2f%%", res.Nodes.Process.CPU.Percent))
+			}
+			if res.Nodes.Process.OpenFileDescriptors != nil {
+				t.Add("File Descriptors", fmt.Sprintf("%d-%d (%d Avg)", res.Nodes.Process.OpenFileDescriptors.Min, res.Nodes.Process.OpenFileDescriptors.Max, res.Nodes.Process.OpenFileDescriptors.Avg))
+			}
+		}
+		t.Add()
+
+		if res.Nodes.OS != nil && res.Nodes.OS.Mem != nil {
+			t.Add("Total Memory", res.Nodes.OS.Mem.Total)
+		}
+		if res.Nodes.JVM != nil {
+			if res.Nodes.JVM.Mem != nil {
+				t.Add("JVM Heap", fmt.Sprintf("%s (%s Max)", res.Nodes.JVM.Mem.HeapUsed, res.Nodes.JVM.Mem.HeapMax))
+			}
+			t.Add("JVM Uptime", res.Nodes.JVM.MaxUptime)
+			t.Add("JVM Threads", res.Nodes.JVM.Threads)
+		}
+		t.Add()
+
+		if res.Nodes.FS != nil {
+			t.Add("Disk Total", res.Nodes.FS.Total)
+			t.Add("Disk Free/Available", fmt.Sprintf("%s/%s", res.Nodes.FS.Free, res.Nodes.FS.Available))
+			t.Add("Disk IO", fmt.Sprintf("%d (%d Read | %d Write)", res.Nodes.FS.DiskIOOp, res.Nodes.FS.DiskReads, res.Nodes.FS.DiskWrites))
+			if res.Nodes.FS.DiskIOSize != "" {
+				t.Add("Disk IO Size", fmt.Sprintf("%s (%s Read | %s Write)", res.Nodes.FS.DiskIOSize, res.Nodes.FS.DiskReadSize, res.Nodes.FS.DiskWriteSize))
+			}
+		}
+		tables = append(tables, t)
+	}
+
+	if res.Nodes != nil && len(res.Nodes.Plugins) > 0 {
+		t = NewTable("Plugins", "Version", "JVM", "Site", "URL", "Description")
+		for _, plugin := range res.Nodes.Plugins {
+			t.Add(plugin.Name, plugin.Version, plugin.JVM, plugin.Site, plugin.URL, plugin.Description)
+		}
+		tables = append(tables, t)
+	}
+
+	return tables
+}
+
+// ClusterNodesTable renders a nodes info response as a table
+func ClusterNodesTable(res *elastic.NodesInfoResponse) *Table {
+	t := NewTable("ID", "Process ID", "Name", "ES Version", "HTTP Address", "Transport Address")
+	for id, node := range res.Nodes {
+		var pid int
+		if node.Process != nil {
+			pid = node.Process.ID
+		}
+		t.Add(id, pid, node.Name, node.Version, node.HTTPAddress, node.TransportAddress)
+	}
+	return t
+}
@@ -0,0 +1,106 @@
+package esu
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+// DefaultRetryStatus are the bulk item statuses retried when a RetryPolicy doesn't list any
+var DefaultRetryStatus = []int{429, 503}
+
+// RetryPolicy controls how bulk items rejected with a retryable status are resent.
+// The wait before retry n is InitialInterval * 2^n, capped at MaxInterval, plus
+// up to Jitter times that again at random.
+type RetryPolicy struct {
+	MaxRetries      int
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Jitter          float64
+	RetryStatus     []int
+}
+
+// NewRetryPolicy creates a retry policy with exponential backoff and 20% jitter
+func NewRetryPolicy(maxRetries int, initial, max time.Duration) *RetryPolicy {
+	return &RetryPolicy{
+		MaxRetries:      maxRetries,
+		InitialInterval: initial,
+		MaxInterval:     max,
+		Jitter:          0.2,
+	}
+}
+
+// Retryable reports whether a bulk item with the given status should be retried
+func (rp *RetryPolicy) Retryable(status int) bool {
+	codes := rp.RetryStatus
+	if len(codes) == 0 {
+		codes = DefaultRetryStatus
+	}
+	for _, code := range codes {
+		if code == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Backoff returns the time to wait before the given retry, counting from 0
+func (rp *RetryPolicy) Backoff(retry int) time.Duration {
+	d := rp.InitialInterval
+	for i := 0; i < retry && (rp.MaxInterval <= 0 || d < rp.MaxInterval); i++ {
+		d *= 2
+	}
+	if rp.MaxInterval > 0 && d > rp.MaxInterval {
+		d = rp.MaxInterval
+	}
+	if rp.Jitter > 0 {
+		d += time.Duration(rand.Float64() * rp.Jitter * float64(d))
+	}
+	return d
+}
+
+// retry resends the retryable failures of a bulk commit until they succeed or
+// the policy gives up or ctx is done. It returns the failures that remain and
+// how many documents were retried and recovered.
+func (rp *RetryPolicy) retry(ctx context.Context, client *elastic.Client, failures []bulkFailure) (remaining []bulkFailure, retried, recovered int) {
+	for attempt := 0; attempt < rp.MaxRetries; attempt++ {
+		var pending []bulkFailure
+		for _, f := range failures {
+			if rp.Retryable(f.item.Status) {
+				pending = append(pending, f)
+			} else {
+				remaining = append(remaining, f)
+			}
+		}
+		failures = pending
+		if len(pending) == 0 {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return append(remaining, failures...), retried, recovered
+		case <-time.After(rp.Backoff(attempt)):
+		}
+
+		requests := make([]elastic.BulkableRequest, len(pending))
+		for i, f := range pending {
+			requests[i] = f.req
+		}
+		retried += len(requests)
+
+		res, err := client.Bulk().Add(requests...).Do(ctx)
+		if err != nil {
+			log.Warningf("Bulk retry %d of %d documents failed: %s", attempt+1, len(requests), err)
+			continue
+		}
+
+		failures = bulkFailures(requests, res)
+		recovered += len(requests) - len(failures)
+	}
+
+	return append(remaining, failures...), retried, recovered
+}
@@ -0,0 +1,518 @@
+package esu
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Schema describes indexes as they should be, read from a file like
+//
+//	indices:
+//	  books_v1:
+//	    settings:
+//	      number_of_shards: 3
+//	      number_of_replicas: 1
+//	    mappings:
+//	      doc:
+//	        properties:
+//	          title: {type: text}
+//	    aliases: [books]
+type Schema struct {
+	Indices map[string]IndexSchema `yaml:"indices"`
+}
+
+// IndexSchema describes an index. Settings and fields that are not mentioned
+// are left alone, and so are the aliases if Aliases is nil.
+type IndexSchema struct {
+	Settings map[string]interface{} `yaml:"settings"`
+	Mappings map[string]interface{} `yaml:"mappings"`
+	Aliases  []string               `yaml:"aliases"`
+}
+
+// staticSettings can only be set when an index is created
+var staticSettings = []string{
+	"number_of_shards",
+	"number_of_routing_shards",
+	"routing_partition_size",
+	"codec",
+	"sort.",
+	"analysis.",
+}
+
+// updatableMappingParams can be changed on existing fields
+var updatableMappingParams = map[string]bool{
+	"ignore_above":    true,
+	"search_analyzer": true,
+	"dynamic":         true,
+	"_meta":           true,
+}
+
+// ChangeAction tells what a Change does
+type ChangeAction string
+
+// Change actions
+const (
+	ChangeCreate ChangeAction = "create"
+	ChangeAdd    ChangeAction = "add"
+	ChangeUpdate ChangeAction = "update"
+	ChangeRemove ChangeAction = "remove"
+)
+
+// Change is a difference between a schema and the cluster
+type Change struct {
+	Index   string
+	Action  ChangeAction
+	Path    string      // dotted path of the setting, field or alias, like settings.number_of_replicas
+	Live    interface{} // the value in the cluster
+	Desired interface{} // the value in the schema
+
+	// Reindex is set when the change can't be made to the existing index
+	Reindex bool
+}
+
+func (c Change) String() string {
+	var s string
+	switch c.Action {
+	case ChangeCreate:
+		s = fmt.Sprintf("+ %s", c.Index)
+	case ChangeAdd:
+		s = fmt.Sprintf("+ %s %s = %v", c.Index, c.Path, c.Desired)
+	case ChangeRemove:
+		s = fmt.Sprintf("- %s %s", c.Index, c.Path)
+	default:
+		s = fmt.Sprintf("~ %s %s: %v => %v", c.Index, c.Path, c.Live, c.Desired)
+	}
+	if c.Reindex {
+		s += " (needs reindex)"
+	}
+	return s
+}
+
+// Plan is the changes that bring the cluster in line with a schema
+type Plan struct {
+	Changes []Change
+
+	schema   *Schema
+	settings map[string]map[string]interface{} // dynamic settings to update, by index
+	mappings map[string]map[string]interface{} // mappings to put, by index
+}
+
+// Empty tells whether the cluster already matches the schema
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// Reindex returns the changes that need a reindex and aren't applied
+func (p *Plan) Reindex() []Change {
+	var changes []Change
+	for _, c := range p.Changes {
+		if c.Reindex {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// LoadSchema reads a schema from the YAML or JSON file at path
+func LoadSchema(path string) (*Schema, error) {
+	data, err := os.ReadFile(expandHome(path))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read schema file %q", path)
+	}
+
+	var schema Schema
+	if err := yaml.Unmarshal(data, &schema); err != nil {
+		return nil, errors.Wrapf(err, "Invalid schema file %q", path)
+	}
+	for name, index := range schema.Indices {
+		index.Settings = stringKeys(index.Settings).(map[string]interface{})
+		index.Mappings = stringKeys(index.Mappings).(map[string]interface{})
+		schema.Indices[name] = index
+	}
+	return &schema, nil
+}
+
+// stringKeys turns the maps YAML decodes into maps with string keys, like JSON
+func stringKeys(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			out[fmt.Sprint(k)] = stringKeys(val)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			out[k] = stringKeys(val)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, val := range v {
+			out[i] = stringKeys(val)
+		}
+		return out
+	case nil:
+		return map[string]interface{}{}
+	default:
+		return v
+	}
+}
+
+// PlanSchema compares the schema with the indexes in the cluster
+func PlanSchema(ctx context.Context, mgr IndexManager, schema *Schema) (*Plan, error) {
+	plan := &Plan{
+		schema:   schema,
+		settings: map[string]map[string]interface{}{},
+		mappings: map[string]map[string]interface{}{},
+	}
+
+	names, err := mgr.GetNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	existing := map[string]bool{}
+	for _, name := range names {
+		existing[name] = true
+	}
+
+	for _, name := range sortedKeys(schema.Indices) {
+		index := schema.Indices[name]
+		if !existing[name] {
+			plan.Changes = append(plan.Changes, Change{Index: name, Action: ChangeCreate})
+			continue
+		}
+
+		if err := plan.diffSettings(ctx, mgr, name, index.Settings); err != nil {
+			return nil, err
+		}
+		if err := plan.diffMappings(ctx, mgr, name, index.Mappings); err != nil {
+			return nil, err
+		}
+		if index.Aliases != nil {
+			if err := plan.diffAliases(ctx, mgr, name, index.Aliases); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return plan, nil
+}
+
+func (p *Plan) diffSettings(ctx context.Context, mgr IndexManager, index string, desired map[string]interface{}) error {
+	if len(desired) == 0 {
+		return nil
+	}
+	live, err := mgr.GetSettings(ctx, index)
+	if err != nil {
+		return err
+	}
+
+	flat := map[string]interface{}{}
+	flattenSettings("", desired, flat)
+	for _, k := range sortedKeys(flat) {
+		value, current := flat[k], live[k]
+		if current != nil && fmt.Sprint(current) == fmt.Sprint(value) {
+			continue
+		}
+
+		c := Change{Index: index, Action: ChangeUpdate, Path: "settings." + k, Live: current, Desired: value}
+		if current == nil {
+			c.Action = ChangeAdd
+		}
+		if isStaticSetting(k) {
+			c.Reindex = true
+		} else {
+			if p.settings[index] == nil {
+				p.settings[index] = map[string]interface{}{}
+			}
+			p.settings[index][k] = value
+		}
+		p.Changes = append(p.Changes, c)
+	}
+	return nil
+}
+
+// flattenSettings adds the settings to flat with dotted keys without the "index." prefix
+func flattenSettings(prefix string, settings map[string]interface{}, flat map[string]interface{}) {
+	for k, v := range settings {
+		key := strings.TrimPrefix(prefix+k, "index.")
+		if nested, ok := v.(map[string]interface{}); ok {
+			flattenSettings(key+".", nested, flat)
+			continue
+		}
+		flat[key] = v
+	}
+}
+
+func isStaticSetting(key string) bool {
+	for _, s := range staticSettings {
+		if key == s || strings.HasSuffix(s, ".") && strings.HasPrefix(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Plan) diffMappings(ctx context.Context, mgr IndexManager, index string, desired map[string]interface{}) error {
+	if len(desired) == 0 {
+		return nil
+	}
+	live, err := mgr.GetMappings(ctx, index)
+	if err != nil {
+		return err
+	}
+
+	// What can be put in place is the desired mappings, with the parts that
+	// need a reindex kept as they are
+	put := jsonMap(desired).copy()
+	var changes []Change
+	_, typeless := desired["properties"]
+	_, liveTypeless := live["properties"]
+	liveName, liveMapping, liveTyped := singleType(live)
+	desiredName, desiredMapping, desiredTyped := singleType(desired)
+	switch {
+	case typeless && liveTyped:
+		// A typeless schema for an index with a type, before ES 7.0
+		changes = diffMapping(index, "mappings."+liveName, liveMapping, desired, put)
+		put = jsonMap{liveName: map[string]interface{}(put)}
+	case typeless:
+		changes = diffMapping(index, "mappings", live, desired, put)
+	case liveTypeless && desiredTyped:
+		// A schema with a type for a typeless index, as of ES 7.0
+		typed := put[desiredName].(map[string]interface{})
+		changes = diffMapping(index, "mappings", live, desiredMapping, typed)
+		put = typed
+	default:
+		for _, typ := range sortedKeys(desired) {
+			path := "mappings." + typ
+			liveType, ok := live[typ].(map[string]interface{})
+			if !ok {
+				changes = append(changes, Change{Index: index, Action: ChangeAdd, Path: path, Desired: desired[typ]})
+				continue
+			}
+			desiredType, _ := desired[typ].(map[string]interface{})
+			changes = append(changes, diffMapping(index, path, liveType, desiredType, put[typ].(map[string]interface{}))...)
+		}
+	}
+
+	if len(changes) > 0 {
+		p.Changes = append(p.Changes, changes...)
+		for _, c := range changes {
+			if !c.Reindex {
+				p.mappings[index] = put
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// singleType returns the only type of mappings given per type, leaving out
+// _default_. It returns false for typeless mappings or several types.
+func singleType(mappings map[string]interface{}) (string, map[string]interface{}, bool) {
+	if _, typeless := mappings["properties"]; typeless {
+		return "", nil, false
+	}
+
+	var name string
+	var mapping map[string]interface{}
+	for typ, m := range mappings {
+		if typ == "_default_" {
+			continue
+		}
+		typed, ok := m.(map[string]interface{})
+		if !ok || name != "" {
+			return "", nil, false
+		}
+		name, mapping = typ, typed
+	}
+	return name, mapping, name != ""
+}
+
+// diffMapping compares a mapping with properties, like a type or an object field.
+// Parameters that need a reindex are reset to the live value in put.
+func diffMapping(index, path string, live, desired, put map[string]interface{}) []Change {
+	var changes []Change
+	for _, k := range sortedKeys(desired) {
+		value := desired[k]
+		current, exists := live[k]
+
+		if k == "properties" || k == "fields" {
+			fields, _ := value.(map[string]interface{})
+			liveFields, _ := current.(map[string]interface{})
+			putFields, _ := put[k].(map[string]interface{})
+			for _, name := range sortedKeys(fields) {
+				fieldPath := path + "." + k + "." + name
+				liveField, ok := liveFields[name].(map[string]interface{})
+				if !ok {
+					changes = append(changes, Change{Index: index, Action: ChangeAdd, Path: fieldPath, Desired: fields[name]})
+					continue
+				}
+				field, _ := fields[name].(map[string]interface{})
+				putField, _ := putFields[name].(map[string]interface{})
+				changes = append(changes, diffField(index, fieldPath, liveField, field, putField)...)
+			}
+			continue
+		}
+
+		if exists && sameValue(current, value) {
+			continue
+		}
+		c := Change{Index: index, Action: ChangeUpdate, Path: path + "." + k, Live: current, Desired: value}
+		if !exists {
+			c.Action = ChangeAdd
+		}
+		if !updatableMappingParams[k] {
+			c.Reindex = true
+			resetParam(put, k, current, exists)
+		}
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+// diffField compares the mapping of a field, which needs a reindex if its type changes
+func diffField(index, path string, live, desired, put map[string]interface{}) []Change {
+	liveType, desiredType := fieldType(live), fieldType(desired)
+	if liveType != desiredType {
+		for k := range put {
+			delete(put, k)
+		}
+		for k, v := range live {
+			put[k] = v
+		}
+		return []Change{{Index: index, Action: ChangeUpdate, Path: path + ".type", Live: liveType, Desired: desiredType, Reindex: true}}
+	}
+
+	withoutType := map[string]interface{}{}
+	for k, v := range desired {
+		if k != "type" {
+			withoutType[k] = v
+		}
+	}
+	return diffMapping(index, path, live, withoutType, put)
+}
+
+// fieldType returns the type of a field mapping, which is object if not given
+func fieldType(field map[string]interface{}) string {
+	if typ, ok := field["type"].(string); ok {
+		return typ
+	}
+	return "object"
+}
+
+func resetParam(put map[string]interface{}, k string, current interface{}, exists bool) {
+	if exists {
+		put[k] = current
+	} else {
+		delete(put, k)
+	}
+}
+
+// sameValue compares mapping values from YAML and JSON, where numbers and
+// booleans may have been given as strings
+func sameValue(a, b interface{}) bool {
+	am, aok := a.(map[string]interface{})
+	bm, bok := b.(map[string]interface{})
+	if aok || bok {
+		if len(am) != len(bm) {
+			return false
+		}
+		for k, v := range am {
+			if w, ok := bm[k]; !ok || !sameValue(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return fmt.Sprint(a) == fmt.Sprint(b)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func (p *Plan) diffAliases(ctx context.Context, mgr IndexManager, index string, desired []string) error {
+	aliases, err := mgr.GetAliases(ctx, index)
+	if err != nil {
+		return err
+	}
+
+	live := map[string]bool{}
+	for _, alias := range aliases[index] {
+		live[alias] = true
+	}
+	wanted := map[string]bool{}
+	for _, alias := range desired {
+		wanted[alias] = true
+		if !live[alias] {
+			p.Changes = append(p.Changes, Change{Index: index, Action: ChangeAdd, Path: "aliases." + alias, Desired: alias})
+		}
+	}
+	for _, alias := range aliases[index] {
+		if !wanted[alias] {
+			p.Changes = append(p.Changes, Change{Index: index, Action: ChangeRemove, Path: "aliases." + alias, Live: alias})
+		}
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ApplyPlan makes the changes of the plan that don't need a reindex. The
+// changes returned by plan.Reindex are left for the caller, for instance
+// to Rebuild the index.
+func ApplyPlan(ctx context.Context, mgr IndexManager, plan *Plan) error {
+	for _, c := range plan.Changes {
+		if c.Reindex {
+			continue
+		}
+
+		switch {
+		case c.Action == ChangeCreate:
+			index := plan.schema.Indices[c.Index]
+			settings := map[string]interface{}{}
+			flattenSettings("", index.Settings, settings)
+			if err := mgr.Create(ctx, c.Index, CreateFlags{Settings: settings, Aliases: index.Aliases}, index.Mappings); err != nil {
+				return err
+			}
+		case strings.HasPrefix(c.Path, "aliases."):
+			alias := strings.TrimPrefix(c.Path, "aliases.")
+			var err error
+			if c.Action == ChangeRemove {
+				err = mgr.RemoveAlias(ctx, c.Index, alias)
+			} else {
+				err = mgr.AddAlias(ctx, c.Index, alias)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, index := range sortedKeys(plan.settings) {
+		if err := mgr.PutSettings(ctx, index, plan.settings[index]); err != nil {
+			return err
+		}
+	}
+	for _, index := range sortedKeys(plan.mappings) {
+		if err := mgr.PutMapping(ctx, index, plan.mappings[index]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
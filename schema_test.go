@@ -0,0 +1,160 @@
+package esu
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSchema = `
+indices:
+  books_v1:
+    settings:
+      number_of_shards: 2
+      index:
+        number_of_replicas: 2
+      refresh_interval: 5s
+    mappings:
+      doc:
+        properties:
+          title: {type: text}
+          year: {type: long}
+          tags: {type: keyword, ignore_above: 256}
+          author: {type: keyword}
+    aliases: [books]
+  authors_v1:
+    settings: {number_of_shards: 1}
+    mappings:
+      doc:
+        properties:
+          name: {type: text}
+    aliases: [authors]
+`
+
+func TestPlanSchema(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "schema.yml")
+	if err := os.WriteFile(path, []byte(testSchema), 0600); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := LoadSchema(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	es, mgr := newFakeCluster(t, "5.6.0")
+	err = mgr.Create(ctx, "books_v1", CreateFlags{Settings: map[string]interface{}{"number_of_shards": 1, "number_of_replicas": 1}}, map[string]interface{}{
+		"doc": map[string]interface{}{"properties": map[string]interface{}{
+			"title": map[string]interface{}{"type": "text"},
+			"year":  map[string]interface{}{"type": "integer"},
+			"tags":  map[string]interface{}{"type": "keyword", "ignore_above": 100},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.AddAlias(ctx, "books_v1", "old"); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := PlanSchema(ctx, mgr, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"+ authors_v1",
+		"~ books_v1 settings.number_of_replicas: 1 => 2",
+		"~ books_v1 settings.number_of_shards: 1 => 2 (needs reindex)",
+		"+ books_v1 settings.refresh_interval = 5s",
+		"+ books_v1 mappings.doc.properties.author = map[type:keyword]",
+		"~ books_v1 mappings.doc.properties.tags.ignore_above: 100 => 256",
+		"~ books_v1 mappings.doc.properties.year.type: integer => long (needs reindex)",
+		"+ books_v1 aliases.books = books",
+		"- books_v1 aliases.old",
+	}
+	var actual []string
+	for _, c := range plan.Changes {
+		actual = append(actual, c.String())
+	}
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expected plan\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+
+	if err := ApplyPlan(ctx, mgr, plan); err != nil {
+		t.Fatal(err)
+	}
+	if es.indices["authors_v1"] == nil || !es.indices["authors_v1"].aliases["authors"] {
+		t.Error("expected authors_v1 to be created with its alias")
+	}
+
+	// Only the changes that need a reindex are left
+	plan, err = PlanSchema(ctx, mgr, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Changes) != 2 || len(plan.Reindex()) != 2 {
+		t.Errorf("expected only the reindex changes left, got %v", plan.Changes)
+	}
+}
+
+func TestPlanSchema_Types(t *testing.T) {
+	for _, tc := range []struct {
+		version  string
+		live     map[string]interface{}
+		schema   string
+		expected string
+		put      string
+	}{
+		{
+			version: "6.8.0",
+			live: map[string]interface{}{"doc": map[string]interface{}{"properties": map[string]interface{}{
+				"title": map[string]interface{}{"type": "text"},
+			}}},
+			schema:   "{properties: {title: {type: text}, year: {type: integer}}}",
+			expected: "+ books mappings.doc.properties.year = map[type:integer]",
+			put:      "PUT /books/_mapping/doc",
+		},
+		{
+			version: "7.10.0",
+			live: map[string]interface{}{"properties": map[string]interface{}{
+				"title": map[string]interface{}{"type": "text"},
+			}},
+			schema:   "{doc: {properties: {title: {type: text}, year: {type: integer}}}}",
+			expected: "+ books mappings.properties.year = map[type:integer]",
+			put:      "PUT /books/_mapping",
+		},
+	} {
+		ctx := context.Background()
+		path := filepath.Join(t.TempDir(), "schema.yml")
+		if err := os.WriteFile(path, []byte("indices:\n  books:\n    mappings: "+tc.schema+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		schema, err := LoadSchema(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		es, mgr := newFakeCluster(t, tc.version)
+		if err := mgr.Create(ctx, "books", CreateFlags{}, tc.live); err != nil {
+			t.Fatal(err)
+		}
+
+		plan, err := PlanSchema(ctx, mgr, schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(plan.Changes) != 1 || plan.Changes[0].String() != tc.expected {
+			t.Errorf("%s: expected %q, got %v", tc.version, tc.expected, plan.Changes)
+		}
+
+		es.calls = nil
+		if err := ApplyPlan(ctx, mgr, plan); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(es.calls, "\n") != tc.put {
+			t.Errorf("%s: expected %s, got %v", tc.version, tc.put, es.calls)
+		}
+	}
+}
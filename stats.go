@@ -0,0 +1,138 @@
+package esu
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+// DefaultProgressInterval is how often OnProgress is called if ProgressInterval isn't set
+const DefaultProgressInterval = 10 * time.Second
+
+// PumpStats is a snapshot of the progress of a Datapump run
+type PumpStats struct {
+	Elapsed time.Duration
+	Rows    int64 // records read from the channel
+	Bytes   int64 // bytes of JSON queued for indexing
+
+	// Rates over the last progress interval, or the whole run once it has finished
+	DocsPerSec  float64
+	BytesPerSec float64
+
+	InFlight  int64 // bulk requests sent but not yet answered
+	Succeeded int64 // documents accepted, including those that succeeded on retry
+	Failed    int64 // documents finally rejected
+	Retried   int64 // documents resent by the retry policy
+	Recovered int64 // documents that succeeded on retry
+
+	// Bulk holds the stats of the underlying bulk processor, including the
+	// queue length and last commit duration of each worker
+	Bulk elastic.BulkProcessorStats
+}
+
+// BulkCommit describes a single bulk request sent by a Datapump, after any retries
+type BulkCommit struct {
+	Docs      int           // documents in the request
+	Succeeded int           // documents accepted, including those that succeeded on retry
+	Failed    int           // documents finally rejected
+	Retried   int           // documents resent by the retry policy
+	Duration  time.Duration // round trip of the initial request
+	Err       error         // set if the request failed as a whole
+}
+
+// pumpRun collects the counters of a single Datapump run
+type pumpRun struct {
+	start time.Time
+
+	// ctx is the context of the run, which stops retries once cancelled
+	ctx context.Context
+
+	rows          int64
+	bytes         int64
+	inFlight      int64
+	retried       int64
+	recovered     int64
+	requestFailed int64
+	rejected      int64 // records that never made it into a bulk request
+
+	mu sync.Mutex
+	p  *elastic.BulkProcessor
+
+	// start time of each bulk request in flight, by execution id
+	commits sync.Map
+}
+
+func newPumpRun(ctx context.Context) *pumpRun {
+	return &pumpRun{start: time.Now(), ctx: ctx}
+}
+
+func (run *pumpRun) setProcessor(p *elastic.BulkProcessor) {
+	run.mu.Lock()
+	run.p = p
+	run.mu.Unlock()
+}
+
+// stats takes a snapshot of the run, with rates averaged over the whole run
+func (run *pumpRun) stats() PumpStats {
+	s := PumpStats{
+		Elapsed:   time.Since(run.start),
+		Rows:      atomic.LoadInt64(&run.rows),
+		Bytes:     atomic.LoadInt64(&run.bytes),
+		InFlight:  atomic.LoadInt64(&run.inFlight),
+		Retried:   atomic.LoadInt64(&run.retried),
+		Recovered: atomic.LoadInt64(&run.recovered),
+	}
+
+	run.mu.Lock()
+	if run.p != nil {
+		s.Bulk = run.p.Stats()
+	}
+	run.mu.Unlock()
+
+	s.Succeeded = s.Bulk.Succeeded + s.Recovered
+	s.Failed = s.Bulk.Failed - s.Recovered + atomic.LoadInt64(&run.requestFailed) + atomic.LoadInt64(&run.rejected)
+
+	if secs := s.Elapsed.Seconds(); secs > 0 {
+		s.DocsPerSec = float64(s.Rows) / secs
+		s.BytesPerSec = float64(s.Bytes) / secs
+	}
+	return s
+}
+
+// reportProgress calls fn with the stats of the run every interval until stop is closed
+func (run *pumpRun) reportProgress(fn func(PumpStats), interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := run.stats()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		s := run.stats()
+		if secs := (s.Elapsed - last.Elapsed).Seconds(); secs > 0 {
+			s.DocsPerSec = float64(s.Rows-last.Rows) / secs
+			s.BytesPerSec = float64(s.Bytes-last.Bytes) / secs
+		}
+		fn(s)
+		last = s
+	}
+}
+
+// Stats returns the stats of the current run, or of the last one if none is in progress
+func (pump *Datapump) Stats() PumpStats {
+	pump.mu.Lock()
+	run := pump.run
+	pump.mu.Unlock()
+
+	if run == nil {
+		return PumpStats{}
+	}
+	return run.stats()
+}
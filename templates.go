@@ -0,0 +1,189 @@
+package esu
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/pkg/errors"
+	logger "github.com/sirupsen/logrus"
+	context "golang.org/x/net/context"
+)
+
+// IndexTemplate holds the settings, mappings and aliases applied to new
+// indexes whose names match one of the patterns.
+type IndexTemplate struct {
+	IndexPatterns []string `json:"index_patterns"`
+
+	// Priority decides which template wins when several match. Legacy
+	// templates call it order, and merge all matching templates instead.
+	Priority int `json:"priority"`
+
+	Settings map[string]interface{} `json:"settings,omitempty"`
+	Mappings map[string]interface{} `json:"mappings,omitempty"`
+	Aliases  map[string]interface{} `json:"aliases,omitempty"`
+}
+
+// legacyTemplate is the body of the _template API
+type legacyTemplate struct {
+	Template      string                 `json:"template,omitempty"`
+	IndexPatterns []string               `json:"index_patterns,omitempty"`
+	Order         int                    `json:"order"`
+	Settings      map[string]interface{} `json:"settings,omitempty"`
+	Mappings      map[string]interface{} `json:"mappings,omitempty"`
+	Aliases       map[string]interface{} `json:"aliases,omitempty"`
+}
+
+// composableTemplate is the body of the _index_template API
+type composableTemplate struct {
+	IndexPatterns []string `json:"index_patterns"`
+	Priority      int      `json:"priority"`
+	Template      struct {
+		Settings map[string]interface{} `json:"settings,omitempty"`
+		Mappings map[string]interface{} `json:"mappings,omitempty"`
+		Aliases  map[string]interface{} `json:"aliases,omitempty"`
+	} `json:"template"`
+}
+
+// composableTemplates is the response of GET _index_template
+type composableTemplates struct {
+	IndexTemplates []struct {
+		Name          string             `json:"name"`
+		IndexTemplate composableTemplate `json:"index_template"`
+	} `json:"index_templates"`
+}
+
+// composableTemplates tells whether the cluster has the composable index
+// template API, added in ES 7.8 to replace legacy templates
+func (mgr *indexManager) composableTemplates() bool {
+	return mgr.esVersion.AtLeast(7, 8)
+}
+
+func templatePath(name string, composable bool) string {
+	path := "/_template"
+	if composable {
+		path = "/_index_template"
+	}
+	if name != "" {
+		path += "/" + url.PathEscape(name)
+	}
+	return path
+}
+
+func (mgr *indexManager) PutTemplate(ctx context.Context, name string, tmpl IndexTemplate) error {
+	logger.Infof("Putting index template %q", name)
+
+	var body interface{}
+	if mgr.composableTemplates() {
+		ct := composableTemplate{IndexPatterns: tmpl.IndexPatterns, Priority: tmpl.Priority}
+		ct.Template.Settings = tmpl.Settings
+		ct.Template.Mappings = tmpl.Mappings
+		ct.Template.Aliases = tmpl.Aliases
+		body = ct
+	} else {
+		lt := legacyTemplate{Order: tmpl.Priority, Settings: tmpl.Settings, Mappings: tmpl.Mappings, Aliases: tmpl.Aliases}
+		if mgr.esVersion.AtLeast(6, 0) {
+			lt.IndexPatterns = tmpl.IndexPatterns
+		} else {
+			// Before ES 6.0 a template matches a single pattern
+			if len(tmpl.IndexPatterns) != 1 {
+				return errors.Errorf("Index template %q must have exactly one pattern on ES %s", name, mgr.esVersion)
+			}
+			lt.Template = tmpl.IndexPatterns[0]
+		}
+		body = lt
+	}
+
+	if _, err := mgr.client.PerformRequest(ctx, "PUT", templatePath(name, mgr.composableTemplates()), nil, body); err != nil {
+		return errors.Wrapf(err, "Unable to put index template %q", name)
+	}
+	return nil
+}
+
+func (mgr *indexManager) GetTemplate(ctx context.Context, name string) (*IndexTemplate, error) {
+	templates, err := mgr.getTemplates(ctx, name, mgr.composableTemplates())
+	if err != nil {
+		return nil, err
+	}
+	if tmpl, ok := templates[name]; ok {
+		return &tmpl, nil
+	}
+	return nil, nil
+}
+
+func (mgr *indexManager) GetTemplateNames(ctx context.Context) ([]string, error) {
+	templates, err := mgr.getTemplates(ctx, "", mgr.composableTemplates())
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// getTemplates returns the composable or legacy template called name, or all
+// of them if name is empty
+func (mgr *indexManager) getTemplates(ctx context.Context, name string, composable bool) (map[string]IndexTemplate, error) {
+	resp, err := mgr.client.PerformRequest(ctx, "GET", templatePath(name, composable), nil, nil, http.StatusNotFound)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not get index templates")
+	}
+	templates := map[string]IndexTemplate{}
+	if resp.StatusCode == http.StatusNotFound {
+		return templates, nil
+	}
+
+	if composable {
+		var res composableTemplates
+		if err := json.Unmarshal(resp.Body, &res); err != nil {
+			return nil, errors.Wrap(err, "Invalid index templates response")
+		}
+		for _, t := range res.IndexTemplates {
+			templates[t.Name] = IndexTemplate{
+				IndexPatterns: t.IndexTemplate.IndexPatterns,
+				Priority:      t.IndexTemplate.Priority,
+				Settings:      t.IndexTemplate.Template.Settings,
+				Mappings:      t.IndexTemplate.Template.Mappings,
+				Aliases:       t.IndexTemplate.Template.Aliases,
+			}
+		}
+		return templates, nil
+	}
+
+	var res map[string]legacyTemplate
+	if err := json.Unmarshal(resp.Body, &res); err != nil {
+		return nil, errors.Wrap(err, "Invalid index templates response")
+	}
+	for name, t := range res {
+		patterns := t.IndexPatterns
+		if t.Template != "" {
+			patterns = []string{t.Template}
+		}
+		templates[name] = IndexTemplate{
+			IndexPatterns: patterns,
+			Priority:      t.Order,
+			Settings:      t.Settings,
+			Mappings:      t.Mappings,
+			Aliases:       t.Aliases,
+		}
+	}
+	return templates, nil
+}
+
+func (mgr *indexManager) DeleteTemplate(ctx context.Context, name string) error {
+	logger.Infof("Deleting index template %q", name)
+
+	resp, err := mgr.client.PerformRequest(ctx, "DELETE", templatePath(name, mgr.composableTemplates()), nil, nil, http.StatusNotFound)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to delete index template %q", name)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		logger.Infof("Index template %q did not exist", name)
+	}
+	return nil
+}
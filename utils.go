@@ -8,9 +8,7 @@ import (
 	"os"
 	"strconv"
 
-	elastic "gopkg.in/olivere/elastic.v5"
-
-	"github.com/fatih/color"
+	"github.com/pkg/errors"
 )
 
 func getConnectionURL(scheme, host, port string) *url.URL {
@@ -21,20 +19,6 @@ func getConnectionURL(scheme, host, port string) *url.URL {
 	}
 }
 
-func connectToES(uri string) (es *elastic.Client) {
-	es, err := elastic.NewClient(
-		elastic.SetURL(uri),
-		elastic.SetSniff(false),
-		elastic.SetHealthcheck(false),
-	)
-
-	if err != nil {
-		exitWithError(err)
-	}
-
-	return es
-}
-
 func getStdIn() io.Reader {
 	info, err := os.Stdin.Stat()
 
@@ -42,7 +26,11 @@ func getStdIn() io.Reader {
 		return nil
 	}
 
-	if info.Size() == 0 {
+	// A terminal, or an empty file redirected to stdin, gives no input
+	if info.Mode()&os.ModeCharDevice != 0 {
+		return nil
+	}
+	if info.Mode().IsRegular() && info.Size() == 0 {
 		return nil
 	}
 
@@ -57,18 +45,29 @@ func getFile(path string) io.Reader {
 	return f
 }
 
+// OpenInput opens the file at path for reading, or stdin if path is empty or "-"
+func OpenInput(path string) (io.ReadCloser, error) {
+	if path == "" || path == "-" {
+		r := getStdIn()
+		if r == nil {
+			return nil, ErrNoInput
+		}
+		return io.NopCloser(r), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to open %q", path)
+	}
+	return f, nil
+}
+
 func readJSON(r io.Reader) (out map[string]interface{}, err error) {
 	d := json.NewDecoder(r)
 	err = d.Decode(&out)
 	return
 }
 
-func exitWithError(err error) {
-	txt := color.New(color.FgRed).SprintfFunc()("\nERROR: %v", err)
-	fmt.Fprintln(os.Stderr, txt)
-	os.Exit(1)
-}
-
 // EnvGetWithDefault gets environment variable, default value returned if it do not exist
 func EnvGetWithDefault(envVar, value string) string {
 	v1 := os.Getenv(envVar)